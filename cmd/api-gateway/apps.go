@@ -0,0 +1,365 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiKeyHeader 提交审核时携带 API Key 的请求头
+	apiKeyHeader = "X-API-Key"
+	// apiKeyPrefix API Key 明文的固定前缀
+	apiKeyPrefix = "sf_"
+	// apiKeyCacheTTL 校验结果的缓存时间，吊销或停用在其他网关实例上最多延迟这么久生效
+	apiKeyCacheTTL = time.Minute
+	// apiKeyMissTTL 无效 Key 校验结果的缓存时间，较短以免随机 Key 长期占用缓存
+	apiKeyMissTTL = 10 * time.Second
+	// apiKeyCacheMax 校验结果缓存的最大条数
+	apiKeyCacheMax = 10000
+	// appIDKey gin 上下文中保存调用方应用 ID 的键
+	appIDKey = "app_id"
+	// apiKeyHashKey gin 上下文中保存调用方 API Key 哈希的键，用于按 Key 限流
+	apiKeyHashKey = "api_key_hash"
+)
+
+var appIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// generateAPIKey 生成新的 API Key，返回明文与哈希
+func generateAPIKey() (key, hash string, err error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(buf)
+	return key, hashAPIKey(key), nil
+}
+
+// hashAPIKey 计算 API Key 的哈希 (Key 为高熵随机串，使用 SHA-256 即可)
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedKey 一次 API Key 校验的结果
+type cachedKey struct {
+	valid     bool
+	appID     string
+	expiresAt *time.Time
+	checkedAt time.Time
+}
+
+// expired 返回缓存的校验结果是否已过期
+func (k cachedKey) expired(now time.Time) bool {
+	ttl := apiKeyCacheTTL
+	if !k.valid {
+		ttl = apiKeyMissTTL
+	}
+	return now.Sub(k.checkedAt) >= ttl
+}
+
+// apiKeyAuth 校验请求携带的 API Key，确定调用方应用
+type apiKeyAuth struct {
+	db       *gorm.DB
+	required bool // 为 false 时允许不带 Key 的请求 (应用 ID 为空)，但携带的 Key 仍须有效
+
+	mu    sync.Mutex
+	cache map[string]cachedKey // Key 哈希 -> 校验结果
+}
+
+func newAPIKeyAuth(db *gorm.DB, required bool) *apiKeyAuth {
+	return &apiKeyAuth{db: db, required: required, cache: make(map[string]cachedKey)}
+}
+
+// Middleware 返回校验 API Key 的中间件，通过后在上下文中记录应用 ID
+func (a *apiKeyAuth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(apiKeyHeader)
+		if key == "" {
+			if a.required {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "缺少 API Key (" + apiKeyHeader + ")"})
+				return
+			}
+			c.Next()
+			return
+		}
+		hash := hashAPIKey(key)
+		entry, err := a.lookup(hash)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !entry.valid || (entry.expiresAt != nil && time.Now().After(*entry.expiresAt)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API Key 无效、已过期或已吊销"})
+			return
+		}
+		c.Set(appIDKey, entry.appID)
+		c.Set(apiKeyHashKey, hash)
+		c.Next()
+	}
+}
+
+// lookup 查询 Key 哈希对应的有效 Key 与启用的应用，有效结果缓存 apiKeyCacheTTL，无效结果缓存 apiKeyMissTTL
+func (a *apiKeyAuth) lookup(hash string) (cachedKey, error) {
+	now := time.Now()
+	a.mu.Lock()
+	entry, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && !entry.expired(now) {
+		return entry, nil
+	}
+
+	var key common.APIKey
+	err := a.db.Joins("JOIN applications ON applications.app_id = api_keys.app_id AND applications.is_enabled = ?", true).
+		Where("api_keys.key_hash = ? AND api_keys.revoked_at IS NULL", hash).
+		First(&key).Error
+	switch {
+	case err == nil:
+		entry = cachedKey{valid: true, appID: key.AppID, expiresAt: key.ExpiresAt, checkedAt: now}
+		// 每次重新校验时更新最近使用时间，相当于按缓存周期采样
+		a.db.Model(&key).UpdateColumn("last_used_at", now)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		entry = cachedKey{checkedAt: now}
+	default:
+		return cachedKey{}, err
+	}
+
+	a.store(hash, entry)
+	return entry, nil
+}
+
+// store 缓存校验结果。缓存满时先清理过期结果，仍然满时清理所有无效结果，再满则不缓存
+func (a *apiKeyAuth) store(hash string, entry cachedKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.cache[hash]; !ok && len(a.cache) >= apiKeyCacheMax {
+		for h, e := range a.cache {
+			if e.expired(entry.checkedAt) {
+				delete(a.cache, h)
+			}
+		}
+		if len(a.cache) >= apiKeyCacheMax {
+			for h, e := range a.cache {
+				if !e.valid {
+					delete(a.cache, h)
+				}
+			}
+		}
+		if len(a.cache) >= apiKeyCacheMax {
+			return
+		}
+	}
+	a.cache[hash] = entry
+}
+
+// invalidate 清空校验缓存，Key 吊销、轮换或应用停用后立即在本实例生效
+func (a *apiKeyAuth) invalidate() {
+	a.mu.Lock()
+	a.cache = make(map[string]cachedKey)
+	a.mu.Unlock()
+}
+
+// registerAppRoutes 注册应用与 API Key 管理 API
+// Key 明文只在创建或轮换时返回一次，数据库只保存哈希
+func registerAppRoutes(admin *gin.RouterGroup, db *gorm.DB, auth *apiKeyAuth, quotas *quotaTracker) {
+	admin.GET("/apps", func(c *gin.Context) {
+		var apps []common.Application
+		db.Order("id").Find(&apps)
+		c.JSON(http.StatusOK, apps)
+	})
+
+	admin.POST("/apps", func(c *gin.Context) {
+		var app common.Application
+		if err := c.ShouldBindJSON(&app); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if app.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name 不能为空"})
+			return
+		}
+		if app.AppID == "" {
+			buf := make([]byte, 4)
+			rand.Read(buf)
+			app.AppID = "app_" + hex.EncodeToString(buf)
+		}
+		if !appIDPattern.MatchString(app.AppID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "app_id 只能包含小写字母、数字、下划线和连字符，最长 64 个字符"})
+			return
+		}
+		app.ID, app.IsEnabled = 0, true
+		if err := db.Create(&app).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, app)
+		c.JSON(http.StatusCreated, app)
+	})
+
+	admin.PUT("/apps/:app_id", func(c *gin.Context) {
+		var app common.Application
+		if err := db.Where("app_id = ?", c.Param("app_id")).First(&app).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+			return
+		}
+		var body struct {
+			Name        string  `json:"name"`
+			Description *string `json:"description"` // 未提供时保留原描述，空字符串表示清空
+			IsEnabled   *bool   `json:"is_enabled"`
+			// 每日 LLM 调用配额 (可选)，0 表示使用默认配额，负数表示不限
+			DailyLLMQuota *int `json:"daily_llm_quota"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		recordBefore(c, app)
+		if body.Name != "" {
+			app.Name = body.Name
+		}
+		if body.Description != nil {
+			app.Description = *body.Description
+		}
+		if body.IsEnabled != nil {
+			app.IsEnabled = *body.IsEnabled
+		}
+		if body.DailyLLMQuota != nil {
+			app.DailyLLMQuota = *body.DailyLLMQuota
+		}
+		if err := db.Save(&app).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		auth.invalidate()
+		quotas.invalidate()
+		recordAfter(c, app)
+		c.JSON(http.StatusOK, app)
+	})
+
+	admin.GET("/apps/:app_id/keys", func(c *gin.Context) {
+		var keys []common.APIKey
+		db.Where("app_id = ?", c.Param("app_id")).Order("id desc").Find(&keys)
+		c.JSON(http.StatusOK, keys)
+	})
+
+	// 为应用创建新的 API Key
+	admin.POST("/apps/:app_id/keys", func(c *gin.Context) {
+		var app common.Application
+		if err := db.Where("app_id = ?", c.Param("app_id")).First(&app).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+			return
+		}
+		var body struct {
+			Name string `json:"name"`
+		}
+		// 请求体可省略
+		if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key, plain, err := createAPIKey(db, app.AppID, body.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, key)
+		c.JSON(http.StatusCreated, gin.H{"key": plain, "api_key": key})
+	})
+
+	// 轮换 API Key: 生成同名新 Key，旧 Key 在宽限期 (grace_period，如 "24h"，默认立即) 后失效
+	admin.POST("/keys/:id/rotate", func(c *gin.Context) {
+		var old common.APIKey
+		if err := db.Where("revoked_at IS NULL").First(&old, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+			return
+		}
+		var body struct {
+			GracePeriod string `json:"grace_period"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var grace time.Duration
+		if body.GracePeriod != "" {
+			var err error
+			if grace, err = time.ParseDuration(body.GracePeriod); err != nil || grace < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period 格式错误: " + body.GracePeriod})
+				return
+			}
+		}
+
+		recordBefore(c, old)
+		var key *common.APIKey
+		var plain string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if key, plain, err = createAPIKey(tx, old.AppID, old.Name); err != nil {
+				return err
+			}
+			now := time.Now()
+			if grace == 0 {
+				return tx.Model(&old).UpdateColumn("revoked_at", now).Error
+			}
+			expires := now.Add(grace)
+			if old.ExpiresAt != nil && old.ExpiresAt.Before(expires) {
+				return nil
+			}
+			return tx.Model(&old).UpdateColumn("expires_at", expires).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		auth.invalidate()
+		recordAfter(c, key)
+		c.JSON(http.StatusCreated, gin.H{"key": plain, "api_key": key, "replaced": old.ID})
+	})
+
+	// 吊销 API Key
+	admin.DELETE("/keys/:id", func(c *gin.Context) {
+		var key common.APIKey
+		if err := db.First(&key, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+			return
+		}
+		recordBefore(c, key)
+		if key.RevokedAt == nil {
+			if err := db.Model(&key).UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		auth.invalidate()
+		recordAfter(c, key)
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// createAPIKey 为应用生成并保存一个新 Key，返回记录与明文
+func createAPIKey(db *gorm.DB, appID, name string) (*common.APIKey, string, error) {
+	plain, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &common.APIKey{
+		AppID:   appID,
+		Name:    name,
+		Prefix:  plain[:len(apiKeyPrefix)+6],
+		KeyHash: hash,
+	}
+	if err := db.Create(key).Error; err != nil {
+		return nil, "", err
+	}
+	return key, plain, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyCacheBounded(t *testing.T) {
+	a := newAPIKeyAuth(nil, true)
+	now := time.Now()
+	for i := 0; i < apiKeyCacheMax; i++ {
+		a.store("miss"+strconv.Itoa(i), cachedKey{checkedAt: now})
+	}
+	if len(a.cache) != apiKeyCacheMax {
+		t.Fatalf("len(cache) = %d, want %d", len(a.cache), apiKeyCacheMax)
+	}
+	// 缓存满时有效结果挤掉无效结果
+	a.store("valid", cachedKey{valid: true, appID: "demo", checkedAt: now})
+	if len(a.cache) != 1 || !a.cache["valid"].valid {
+		t.Fatalf("len(cache) = %d after storing a valid key into a full cache of misses", len(a.cache))
+	}
+}
+
+func TestAPIKeyCacheEvictsExpiredMisses(t *testing.T) {
+	a := newAPIKeyAuth(nil, true)
+	old := time.Now().Add(-apiKeyMissTTL)
+	for i := 0; i < apiKeyCacheMax; i++ {
+		a.store("miss"+strconv.Itoa(i), cachedKey{checkedAt: old})
+	}
+	a.store("new", cachedKey{checkedAt: time.Now()})
+	if len(a.cache) != 1 {
+		t.Fatalf("len(cache) = %d, want expired misses evicted", len(a.cache))
+	}
+}
+
+func TestCachedKeyExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		entry cachedKey
+		want  bool
+	}{
+		{"新的有效结果", cachedKey{valid: true, checkedAt: now.Add(-apiKeyMissTTL)}, false},
+		{"过期的有效结果", cachedKey{valid: true, checkedAt: now.Add(-apiKeyCacheTTL)}, true},
+		{"新的无效结果", cachedKey{checkedAt: now.Add(-time.Second)}, false},
+		{"过期的无效结果", cachedKey{checkedAt: now.Add(-apiKeyMissTTL)}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.expired(now); got != tt.want {
+			t.Errorf("%s: expired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashAPIKey(key) != hash || len(hash) != 64 || key[:len(apiKeyPrefix)] != apiKeyPrefix {
+		t.Fatalf("generateAPIKey() = %q, %q", key, hash)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/safeflow-project/safeflow/internal/common"
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+	"gorm.io/gorm"
+)
+
+const (
+	// submissionConsumer 异步审核工作协程使用的 JetStream 持久消费者，多个网关实例共享，每条提交只由一个实例处理
+	submissionConsumer = "api-gateway-submissions"
+	// submissionAckWait 单条提交的处理时限，超时未确认的消息会重新投递
+	submissionAckWait = 2 * time.Minute
+	// submissionScanTimeout 单条提交的审核时限，留出保存结果的时间，保证在 AckWait 之前确认
+	submissionScanTimeout = submissionAckWait - 30*time.Second
+	// submissionMaxDeliver 规则引擎调用失败时最多尝试的次数，超过后任务记为失败
+	// 消费者本身不限制投递次数，保存结果失败 (数据库故障) 时一直重试，避免任务永远停留在 pending
+	submissionMaxDeliver = 3
+	// submissionRetryDelay 处理失败后重新投递的间隔
+	submissionRetryDelay = 5 * time.Second
+	// maxScanErrorLen 任务失败原因的最大保存长度 (字节)
+	maxScanErrorLen = 512
+)
+
+// registerAsyncRoutes 注册异步提交 API，只在本实例的工作协程启动成功后注册
+// POST /submit/async 保存任务并发布 content.submitted 后立即返回 request_id，调用方通过 GET /results/:request_id 轮询结果
+func registerAsyncRoutes(r gin.IRoutes, db *gorm.DB, js jetstream.JetStream, limits *submitLimits) {
+	r.POST("/submit/async", func(c *gin.Context) {
+		var reqBody struct {
+			Content      string `json:"content" binding:"required"`
+			UserID       string `json:"user_id" binding:"max=64"`
+			Scene        string `json:"scene"`
+			Language     string `json:"language"`
+			Mode         string `json:"mode"`
+			IncludeTrace bool   `json:"include_trace"`
+		}
+		if err := c.ShouldBindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validScanMode(reqBody.Mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的审核模式: " + reqBody.Mode})
+			return
+		}
+		if !limits.admit(c, reqBody.UserID, 1) {
+			return
+		}
+
+		event := common.ContentSubmittedEvent{
+			RequestID:    uuid.New().String(),
+			UserID:       reqBody.UserID,
+			Content:      reqBody.Content,
+			AppID:        c.GetString(appIDKey),
+			Scene:        reqBody.Scene,
+			Language:     reqBody.Language,
+			Mode:         reqBody.Mode,
+			IncludeTrace: reqBody.IncludeTrace,
+			Timestamp:    time.Now(),
+		}
+		// 先保存任务再发布事件，工作协程据此判断任务是否已处理
+		result := common.ScanResult{
+			RequestID: event.RequestID,
+			AppID:     event.AppID,
+			UserID:    event.UserID,
+			Status:    common.ScanStatusPending,
+		}
+		if err := db.Create(&result).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		data, _ := json.Marshal(event)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+		if _, err := js.Publish(ctx, common.SubjectContentSubmitted, data); err != nil {
+			finishScan(db, &result, nil, "提交到审核队列失败: "+err.Error())
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "审核队列暂时不可用: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"request_id": event.RequestID,
+			"status":     result.Status,
+		})
+	})
+}
+
+// registerResultRoutes 注册异步审核结果查询 API，只能查询本应用提交的任务
+// 任务可能由其他网关实例受理，因此即使本实例的工作协程未启动也提供查询
+func registerResultRoutes(r gin.IRoutes, db *gorm.DB) {
+	r.GET("/results/:request_id", func(c *gin.Context) {
+		var result common.ScanResult
+		err := db.Where("request_id = ? AND app_id = ?", c.Param("request_id"), c.GetString(appIDKey)).First(&result).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "审核任务不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		body := gin.H{
+			"request_id":   result.RequestID,
+			"status":       result.Status,
+			"created_at":   result.CreatedAt,
+			"completed_at": result.CompletedAt,
+		}
+		if result.Response != "" {
+			body["result"] = json.RawMessage(result.Response)
+		}
+		if result.Error != "" {
+			body["error"] = result.Error
+		}
+		c.JSON(http.StatusOK, body)
+	})
+}
+
+// startSubmissionWorkers 启动 workers 个工作协程，通过持久消费者处理 content.submitted 中的异步提交
+// 消息保存在 JetStream 中，网关重启后未处理完的提交会继续处理
+func startSubmissionWorkers(js jetstream.JetStream, db *gorm.DB, moderation *pipeline, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	consumer, err := js.CreateOrUpdateConsumer(ctx, common.StreamName, jetstream.ConsumerConfig{
+		Durable:       submissionConsumer,
+		FilterSubject: common.SubjectContentSubmitted,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       submissionAckWait,
+		MaxDeliver:    -1,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 回调在工作协程都忙时阻塞，本实例最多预取 workers 条消息
+	jobs := make(chan jetstream.Msg)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for msg := range jobs {
+				processSubmission(db, moderation, msg)
+			}
+		}()
+	}
+	_, err = consumer.Consume(func(msg jetstream.Msg) {
+		jobs <- msg
+	}, jetstream.PullMaxMessages(workers))
+	return err
+}
+
+// processSubmission 处理一条异步提交: 走与同步提交相同的审核流程并保存结果
+// 规则引擎调用失败时延迟重试，超过尝试次数后任务记为失败；保存结果失败时一直延迟重试；
+// 重复投递的已完成任务直接确认
+func processSubmission(db *gorm.DB, moderation *pipeline, msg jetstream.Msg) {
+	var event common.ContentSubmittedEvent
+	if err := json.Unmarshal(msg.Data(), &event); err != nil {
+		log.Printf("丢弃无法解析的提交事件: %v", err)
+		msg.Term()
+		return
+	}
+
+	var result common.ScanResult
+	if err := db.Where("request_id = ?", event.RequestID).First(&result).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("丢弃没有对应任务的提交事件: %s", event.RequestID)
+			msg.Term()
+			return
+		}
+		log.Printf("查询审核任务 %s 失败: %v", event.RequestID, err)
+		msg.NakWithDelay(submissionRetryDelay)
+		return
+	}
+	if result.Status != common.ScanStatusPending {
+		msg.Ack()
+		return
+	}
+
+	req := &safeflow.ScanRequest{
+		RequestId:    event.RequestID,
+		UserId:       event.UserID,
+		Content:      event.Content,
+		Scene:        event.Scene,
+		Language:     event.Language,
+		Mode:         event.Mode,
+		IncludeTrace: event.IncludeTrace,
+		AppId:        event.AppID,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), submissionScanTimeout)
+	defer cancel()
+	resp, err := moderation.scan(ctx, req, "")
+	var quotaErr *quotaExceededError
+	switch {
+	case errors.As(err, &quotaErr):
+		err = finishScan(db, &result, nil, quotaErr.Error())
+	case err != nil:
+		if meta, metaErr := msg.Metadata(); metaErr == nil && meta.NumDelivered < submissionMaxDeliver {
+			log.Printf("审核任务 %s 处理失败，稍后重试: %v", event.RequestID, err)
+			msg.NakWithDelay(submissionRetryDelay)
+			return
+		}
+		err = finishScan(db, &result, nil, err.Error())
+	default:
+		err = finishScan(db, &result, resp, "")
+	}
+	if err != nil {
+		log.Printf("保存审核任务 %s 的结果失败: %v", event.RequestID, err)
+		msg.NakWithDelay(submissionRetryDelay)
+		return
+	}
+	msg.Ack()
+}
+
+// finishScan 保存任务的结论 (resp 不为空时) 或失败原因，只更新仍在等待中的任务
+func finishScan(db *gorm.DB, result *common.ScanResult, resp *safeflow.ScanResponse, errMsg string) error {
+	now := time.Now()
+	updates := map[string]any{"completed_at": now}
+	if resp != nil {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		updates["status"] = common.ScanStatusDone
+		updates["action"] = resp.Action
+		updates["response"] = string(data)
+	} else {
+		if len(errMsg) > maxScanErrorLen {
+			errMsg = strings.ToValidUTF8(errMsg[:maxScanErrorLen], "")
+		}
+		updates["status"] = common.ScanStatusFailed
+		updates["error"] = errMsg
+	}
+	return db.Model(&common.ScanResult{}).
+		Where("id = ? AND status = ?", result.ID, common.ScanStatusPending).
+		Updates(updates).Error
+}
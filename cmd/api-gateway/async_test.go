@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSubmitAsyncValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerAsyncRoutes(r, nil, nil, nil)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"缺少内容", `{"user_id": "u1"}`},
+		{"user_id 过长", `{"content": "hi", "user_id": "` + strings.Repeat("u", 65) + `"}`},
+		{"不支持的审核模式", `{"content": "hi", "mode": "unknown"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/submit/async", strings.NewReader(tt.body)))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
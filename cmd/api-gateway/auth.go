@@ -0,0 +1,447 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+const (
+	// adminUserKey gin 上下文中保存当前登录管理员的键
+	adminUserKey = "admin_user"
+	// passwordIterations PBKDF2-SHA256 的迭代次数
+	passwordIterations = 600000
+	// minPasswordLength 管理员密码的最小长度
+	minPasswordLength = 8
+	// 登录限流: 每个客户端 IP 每分钟 10 次，每个用户名每分钟 5 次
+	loginIPPerMinute   = 10
+	loginUserPerMinute = 5
+)
+
+// 权限，按路由分组授予
+const (
+	permPolicy = "policy" // 维护规则、案例、词典、提示词与策略快照
+	permReview = "review" // 复核审核结果、沉淀案例
+	permAudit  = "audit"  // 查看审计日志与执行轨迹
+	permSystem = "system" // 管理应用与 API Key、管理员账号，查看操作日志与运行指标
+)
+
+// rolePermissions 各角色拥有的权限
+var rolePermissions = map[string]map[string]bool{
+	common.RoleAdmin:        {permPolicy: true, permReview: true, permAudit: true, permSystem: true},
+	common.RolePolicyEditor: {permPolicy: true},
+	common.RoleReviewer:     {permReview: true, permAudit: true},
+	common.RoleAuditor:      {permAudit: true},
+}
+
+// hashPassword 计算密码哈希，格式为 pbkdf2-sha256$迭代次数$盐$哈希
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword 校验密码是否与哈希匹配
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// dummyPasswordHash 用户不存在时参与校验的哈希，使登录耗时不暴露用户名是否存在
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("")
+	return hash
+})
+
+// tokenClaims 是登录令牌的内容，角色与启用状态在每次请求时从数据库读取，修改后立即生效；
+// 令牌版本与账号当前版本不一致 (修改过密码或停用过) 时令牌失效
+type tokenClaims struct {
+	UserID    uint  `json:"uid"`
+	Version   uint  `json:"ver"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// tokenSigner 签发和校验 HMAC-SHA256 签名的登录令牌 (base64url(claims).base64url(签名))
+type tokenSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// newTokenSigner 创建令牌签发器，secret 为空时随机生成 (网关重启或多实例部署时令牌互不通用)
+func newTokenSigner(secret string, ttl time.Duration) *tokenSigner {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+		log.Printf("警告: 未配置 ADMIN_TOKEN_SECRET，使用随机密钥签发登录令牌，重启后需重新登录")
+	}
+	return &tokenSigner{secret: key, ttl: ttl}
+}
+
+func (s *tokenSigner) sign(user *common.AdminUser) (string, time.Time) {
+	expires := time.Now().Add(s.ttl)
+	payload, _ := json.Marshal(tokenClaims{UserID: user.ID, Version: user.TokenVersion, ExpiresAt: expires.Unix()})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.mac(encoded), expires
+}
+
+func (s *tokenSigner) mac(encoded string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// verify 校验令牌签名与有效期，返回令牌内容
+func (s *tokenSigner) verify(token string) (tokenClaims, error) {
+	var claims tokenClaims
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.mac(encoded))) {
+		return claims, errors.New("令牌无效")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, errors.New("令牌无效")
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.UserID == 0 {
+		return claims, errors.New("令牌无效")
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return claims, errors.New("令牌已过期，请重新登录")
+	}
+	return claims, nil
+}
+
+// adminAuthMiddleware 校验 Authorization: Bearer 令牌，并在上下文中记录当前管理员
+func adminAuthMiddleware(db *gorm.DB, signer *tokenSigner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "请先登录 (Authorization: Bearer <token>)"})
+			return
+		}
+		claims, err := signer.verify(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		var user common.AdminUser
+		if err := db.First(&user, claims.UserID).Error; err != nil || !user.IsEnabled {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "账号不存在或已停用"})
+			return
+		}
+		if claims.Version != user.TokenVersion {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "令牌已失效，请重新登录"})
+			return
+		}
+		c.Set(adminUserKey, &user)
+		c.Next()
+	}
+}
+
+// currentAdmin 返回当前登录的管理员
+func currentAdmin(c *gin.Context) *common.AdminUser {
+	user, _ := c.Get(adminUserKey)
+	u, _ := user.(*common.AdminUser)
+	return u
+}
+
+// requirePermission 要求当前管理员的角色拥有指定权限
+// 限定了应用的账号只能操作该应用的数据，不能管理系统配置
+func requirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentAdmin(c)
+		if user == nil || !rolePermissions[user.Role][perm] || (perm == permSystem && user.AppID != "") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "没有权限执行此操作"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ensureInitialAdmin 在还没有任何管理员账号时创建初始管理员
+func ensureInitialAdmin(db *gorm.DB, username, password string) error {
+	var count int64
+	if err := db.Model(&common.AdminUser{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	generated := password == ""
+	if generated {
+		buf := make([]byte, 12)
+		rand.Read(buf)
+		password = hex.EncodeToString(buf)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := db.Create(&common.AdminUser{Username: username, PasswordHash: hash, Role: common.RoleAdmin, IsEnabled: true}).Error; err != nil {
+		return err
+	}
+	if generated {
+		log.Printf("已创建初始管理员 %s，随机密码: %s (请登录后修改)", username, password)
+	} else {
+		log.Printf("已创建初始管理员 %s", username)
+	}
+	return nil
+}
+
+// registerLoginRoute 注册登录 API (无需令牌)，按客户端 IP 和用户名限流
+func registerLoginRoute(admin *gin.RouterGroup, db *gorm.DB, signer *tokenSigner) {
+	byIP := newRateLimiter(loginIPPerMinute/60.0, loginIPPerMinute)
+	byUser := newRateLimiter(loginUserPerMinute/60.0, loginUserPerMinute)
+	admin.POST("/login", func(c *gin.Context) {
+		var body struct {
+			Username string `json:"username" binding:"required"`
+			Password string `json:"password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if ok, wait := byIP.allow(c.ClientIP(), 1); !ok {
+			tooManyRequests(c, "登录尝试过于频繁，请稍后重试", wait)
+			return
+		}
+		if ok, wait := byUser.allow(strings.ToLower(body.Username), 1); !ok {
+			tooManyRequests(c, "登录尝试过于频繁，请稍后重试", wait)
+			return
+		}
+
+		var user common.AdminUser
+		err := db.Where("username = ?", body.Username).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// 用户不存在时也计算一次哈希，各种失败情况的耗时相同
+		hash := dummyPasswordHash()
+		if err == nil {
+			hash = user.PasswordHash
+		}
+		if !checkPassword(hash, body.Password) || err != nil || !user.IsEnabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+			return
+		}
+		now := time.Now()
+		db.Model(&user).UpdateColumn("last_login_at", now)
+		token, expires := signer.sign(&user)
+		c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": expires, "user": user})
+	})
+}
+
+// registerUserRoutes 注册管理员账号 API
+// /me 对所有登录用户开放，账号管理需要 system 权限
+func registerUserRoutes(admin, system *gin.RouterGroup, db *gorm.DB, signer *tokenSigner) {
+	admin.GET("/me", func(c *gin.Context) {
+		user := currentAdmin(c)
+		perms := make([]string, 0, len(rolePermissions[user.Role]))
+		for perm := range rolePermissions[user.Role] {
+			perms = append(perms, perm)
+		}
+		sort.Strings(perms)
+		c.JSON(http.StatusOK, gin.H{"user": user, "permissions": perms})
+	})
+
+	// 修改自己的密码，之前签发的令牌全部失效，返回新令牌
+	admin.PUT("/me/password", func(c *gin.Context) {
+		var body struct {
+			OldPassword string `json:"old_password" binding:"required"`
+			NewPassword string `json:"new_password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user := currentAdmin(c)
+		if !checkPassword(user.PasswordHash, body.OldPassword) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "原密码错误"})
+			return
+		}
+		if err := setPassword(db, user, body.NewPassword); err != nil {
+			c.JSON(passwordErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		token, expires := signer.sign(user)
+		c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": expires})
+	})
+
+	system.GET("/users", func(c *gin.Context) {
+		var users []common.AdminUser
+		db.Order("id").Find(&users)
+		c.JSON(http.StatusOK, users)
+	})
+
+	system.POST("/users", func(c *gin.Context) {
+		var body struct {
+			Username string `json:"username" binding:"required"`
+			Password string `json:"password" binding:"required"`
+			Role     string `json:"role" binding:"required,oneof=admin policy_editor reviewer auditor"`
+			AppID    string `json:"app_id"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(body.Password) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errWeakPassword.Error()})
+			return
+		}
+		if !validateUserApp(c, db, body.AppID) {
+			return
+		}
+		hash, err := hashPassword(body.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		user := common.AdminUser{Username: body.Username, PasswordHash: hash, Role: body.Role, AppID: body.AppID, IsEnabled: true}
+		if err := db.Create(&user).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, user)
+		c.JSON(http.StatusCreated, user)
+	})
+
+	// 修改角色、限定的应用、启用状态或重置密码
+	system.PUT("/users/:id", func(c *gin.Context) {
+		var user common.AdminUser
+		if err := db.First(&user, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		var body struct {
+			Role      string  `json:"role" binding:"omitempty,oneof=admin policy_editor reviewer auditor"`
+			AppID     *string `json:"app_id"`
+			IsEnabled *bool   `json:"is_enabled"`
+			Password  string  `json:"password"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if body.Password != "" && len(body.Password) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errWeakPassword.Error()})
+			return
+		}
+		if body.AppID != nil && !validateUserApp(c, db, *body.AppID) {
+			return
+		}
+		// 不能停用自己或取消自己的管理员角色，避免系统中没有可用的管理员
+		if user.ID == currentAdmin(c).ID && ((body.Role != "" && body.Role != common.RoleAdmin) || (body.IsEnabled != nil && !*body.IsEnabled)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能停用自己或修改自己的角色"})
+			return
+		}
+		recordBefore(c, user)
+		if body.Role != "" {
+			user.Role = body.Role
+		}
+		if body.AppID != nil {
+			user.AppID = *body.AppID
+		}
+		if body.IsEnabled != nil {
+			// 停用时使已签发的令牌失效，重新启用后也需要重新登录
+			if user.IsEnabled && !*body.IsEnabled {
+				user.TokenVersion++
+			}
+			user.IsEnabled = *body.IsEnabled
+		}
+		if err := db.Save(&user).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if body.Password != "" {
+			if err := setPassword(db, &user, body.Password); err != nil {
+				c.JSON(passwordErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+		}
+		recordAfter(c, user)
+		c.JSON(http.StatusOK, user)
+	})
+}
+
+// errWeakPassword 密码不满足长度要求
+var errWeakPassword = fmt.Errorf("密码长度不能少于 %d 个字符", minPasswordLength)
+
+func passwordErrorStatus(err error) int {
+	if errors.Is(err, errWeakPassword) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// validateUserApp 校验账号限定的应用存在 (为空表示不限定)，不存在时返回 400
+func validateUserApp(c *gin.Context, db *gorm.DB, appID string) bool {
+	if appID == "" {
+		return true
+	}
+	exists, err := appExists(db, appID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "应用不存在: " + appID})
+		return false
+	}
+	return true
+}
+
+// setPassword 校验并保存新密码，同时递增令牌版本使已签发的令牌失效
+func setPassword(db *gorm.DB, user *common.AdminUser, password string) error {
+	if len(password) < minPasswordLength {
+		return errWeakPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.TokenVersion++
+	return db.Model(user).UpdateColumns(map[string]any{"password_hash": hash, "token_version": user.TokenVersion}).Error
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := hashPassword("correct horse")
+	if hash == other {
+		t.Fatal("hashes of the same password should use different salts")
+	}
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"正确密码", hash, "correct horse", true},
+		{"错误密码", hash, "wrong horse", false},
+		{"空密码", hash, "", false},
+		{"格式错误", "plain", "correct horse", false},
+		{"算法不支持", strings.Replace(hash, "pbkdf2-sha256", "md5", 1), "correct horse", false},
+		{"迭代次数错误", "pbkdf2-sha256$x$AAAA$AAAA", "correct horse", false},
+	}
+	for _, tt := range tests {
+		if got := checkPassword(tt.hash, tt.password); got != tt.want {
+			t.Errorf("%s: checkPassword = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDummyPasswordHashRejects(t *testing.T) {
+	if checkPassword(dummyPasswordHash(), "anything") {
+		t.Fatal("dummy hash accepted a password")
+	}
+}
+
+func TestTokenSignVerify(t *testing.T) {
+	signer := newTokenSigner("secret", time.Hour)
+	user := &common.AdminUser{ID: 7, TokenVersion: 3}
+	token, expires := signer.sign(user)
+	if time.Until(expires) <= 0 {
+		t.Fatalf("expires = %v", expires)
+	}
+
+	claims, err := signer.verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 7 || claims.Version != 3 {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	encoded, sig, _ := strings.Cut(token, ".")
+	forged, _ := newTokenSigner("other", time.Hour).sign(user)
+	expired, _ := newTokenSigner("secret", -time.Second).sign(user)
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"空令牌", ""},
+		{"缺少签名", encoded},
+		{"篡改内容", "e30." + sig},
+		{"篡改签名", encoded + "." + strings.Repeat("A", len(sig))},
+		{"其他密钥签发", forged},
+		{"已过期", expired},
+	}
+	for _, tt := range tests {
+		if _, err := signer.verify(tt.token); err == nil {
+			t.Errorf("%s: verify succeeded", tt.name)
+		}
+	}
+}
+
+func TestRequirePermissionMatrix(t *testing.T) {
+	tests := []struct {
+		role string
+		perm string
+		want bool
+	}{
+		{common.RoleAdmin, permSystem, true},
+		{common.RolePolicyEditor, permPolicy, true},
+		{common.RolePolicyEditor, permAudit, false},
+		{common.RoleReviewer, permReview, true},
+		{common.RoleReviewer, permAudit, true},
+		{common.RoleReviewer, permPolicy, false},
+		{common.RoleAuditor, permAudit, true},
+		{common.RoleAuditor, permReview, false},
+		{"unknown", permAudit, false},
+	}
+	for _, tt := range tests {
+		if got := rolePermissions[tt.role][tt.perm]; got != tt.want {
+			t.Errorf("%s/%s = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"github.com/safeflow-project/safeflow/internal/vectordb"
+	"gorm.io/gorm"
+)
+
+const (
+	// caseSyncTimeout 单次同步案例向量 (Embedding + 写入 Milvus) 的超时时间
+	caseSyncTimeout = 30 * time.Second
+	// caseReconcileGrace 最近修改过的案例可能正在同步向量 (向量已写入、vector_id 尚未回写)，对账时跳过
+	caseReconcileGrace = 2 * caseSyncTimeout
+	// maxCasePageSize 案例列表每页最多返回的条数
+	maxCasePageSize = 200
+	// defaultSimilarTopK、maxSimilarTopK 相似案例检索的默认与最大返回条数
+	defaultSimilarTopK = 5
+	maxSimilarTopK     = 50
+)
+
+// registerCaseRoutes 注册案例库管理 API
+// 案例写入 MySQL 后同步写入 Milvus 案例集合；store 为 nil (未配置 Embedding 或 Milvus 不可用) 时只写 MySQL，
+// 同步失败的案例 vector_id 为 0，由对账任务补齐。案例按应用隔离，应用的案例只参与该应用的审核
+func registerCaseRoutes(admin, system *gin.RouterGroup, db *gorm.DB, nc *nats.Conn, store *vectordb.CaseStore) {
+	// 分页查询案例，支持按标签、类别、是否自定义、关键词、来源请求筛选；flagged=true 只返回存在待处理质量问题的案例
+	admin.GET("/cases", func(c *gin.Context) {
+		var cases []common.Case
+		query := scoped(db, c).Model(&common.Case{}).Order("created_at desc")
+
+		if label := c.Query("label"); label != "" {
+			query = query.Where("label = ?", label)
+		}
+		if category := c.Query("category"); category != "" {
+			query = query.Where("category = ?", category)
+		}
+		if custom := c.Query("is_custom"); custom != "" {
+			query = query.Where("is_custom = ?", custom == "true")
+		}
+		if keyword := c.Query("keyword"); keyword != "" {
+			query = query.Where("content LIKE ?", "%"+keyword+"%")
+		}
+		if requestID := c.Query("source_request_id"); requestID != "" {
+			query = query.Where("source_request_id = ?", requestID)
+		}
+		if c.Query("flagged") == "true" {
+			flagged := func(column string) *gorm.DB {
+				return db.Model(&common.CaseIssue{}).Select(column).Where("status = ?", common.CaseIssueOpen)
+			}
+			query = query.Where("id IN (?) OR id IN (?)", flagged("case_id"), flagged("other_case_id"))
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if pageSize <= 0 {
+			pageSize = 20
+		}
+		pageSize = min(pageSize, maxCasePageSize)
+
+		var total int64
+		query.Count(&total)
+		query.Limit(pageSize).Offset((page - 1) * pageSize).Find(&cases)
+
+		c.JSON(http.StatusOK, gin.H{
+			"total": total,
+			"page":  page,
+			"data":  cases,
+		})
+	})
+
+	// 检索与给定文本 (或已有案例) 相似的案例，范围为当前应用可见的案例 (应用自己的与全局的)
+	admin.POST("/cases/similar", func(c *gin.Context) {
+		skipOperationLog(c)
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "向量库未配置"})
+			return
+		}
+		var body struct {
+			Content  string `json:"content"`
+			CaseID   uint   `json:"case_id"` // 以已有案例的内容检索 (结果不包含该案例本身)
+			TopK     int    `json:"top_k"`
+			Label    string `json:"label"`
+			Category string `json:"category"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if body.CaseID != 0 {
+			var kase common.Case
+			if err := scoped(db, c).First(&kase, body.CaseID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+				return
+			}
+			body.Content = kase.Content
+		}
+		if strings.TrimSpace(body.Content) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content 或 case_id 不能为空"})
+			return
+		}
+		if body.TopK <= 0 {
+			body.TopK = defaultSimilarTopK
+		}
+		body.TopK = min(body.TopK, maxSimilarTopK)
+
+		hits, err := store.Search(c.Request.Context(), body.Content, body.TopK, caseFilterExpr(tenantOf(c), body.Label, body.Category, body.CaseID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"metric": store.Metric(), "data": hits})
+	})
+
+	admin.POST("/cases", func(c *gin.Context) {
+		var kase common.Case
+		if err := c.ShouldBindJSON(&kase); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validCaseContent(c, kase.Content) {
+			return
+		}
+		kase.ID, kase.VectorID = 0, 0
+		kase.IsCustom, kase.AppID = true, tenantOf(c)
+		if err := db.Create(&kase).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		syncCase(c.Request.Context(), db, store, &kase)
+		recordAfter(c, kase)
+		publishPolicyChanged(nc, "case", "case:"+strconv.Itoa(int(kase.ID)))
+		c.JSON(http.StatusCreated, kase)
+	})
+
+	admin.PUT("/cases/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var kase common.Case
+		if err := scoped(db, c).First(&kase, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+			return
+		}
+		var body struct {
+			Content  string `json:"content" binding:"required"`
+			Label    string `json:"label"`
+			Category string `json:"category"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validCaseContent(c, body.Content) {
+			return
+		}
+		recordBefore(c, kase)
+		changed := body.Content != kase.Content || body.Label != kase.Label || body.Category != kase.Category
+		kase.Content, kase.Label, kase.Category = body.Content, body.Label, body.Category
+		if err := db.Save(&kase).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if changed || kase.VectorID == 0 {
+			syncCase(c.Request.Context(), db, store, &kase)
+		}
+		recordAfter(c, kase)
+		publishPolicyChanged(nc, "case", "case:"+id)
+		c.JSON(http.StatusOK, kase)
+	})
+
+	admin.DELETE("/cases/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var kase common.Case
+		if err := scoped(db, c).First(&kase, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+			return
+		}
+		recordBefore(c, kase)
+		// 先软删除 MySQL 记录，向量删除失败时由对账任务清理
+		if err := db.Delete(&kase).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if store != nil && kase.VectorID != 0 {
+			if err := store.Delete(c.Request.Context(), kase.VectorID); err != nil {
+				log.Printf("删除案例向量失败 (case=%s): %v", id, err)
+			}
+		}
+		publishPolicyChanged(nc, "case", "case:"+id)
+		c.Status(http.StatusNoContent)
+	})
+
+	// 立即执行一次 MySQL 与 Milvus 的对账 (涉及所有应用的案例，只允许系统管理员执行)
+	system.POST("/cases/reconcile", func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "向量库未配置"})
+			return
+		}
+		upserted, deleted, err := reconcileCases(c.Request.Context(), db, store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if upserted > 0 || deleted > 0 {
+			publishPolicyChanged(nc, "case", "")
+		}
+		result := gin.H{"upserted": upserted, "deleted": deleted}
+		recordAfter(c, result)
+		c.JSON(http.StatusOK, result)
+	})
+}
+
+// caseFilterExpr 生成相似案例检索的 Milvus 过滤表达式，只检索全局案例与 appID 的案例，excludeCase 不为 0 时排除该案例
+func caseFilterExpr(appID, label, category string, excludeCase uint) string {
+	parts := []string{vectordb.FieldAppID + " == " + strconv.Quote("")}
+	if appID != "" {
+		parts[0] = vectordb.FieldAppID + " in [" + strconv.Quote("") + ", " + strconv.Quote(appID) + "]"
+	}
+	if label != "" {
+		parts = append(parts, vectordb.FieldLabel+" == "+strconv.Quote(label))
+	}
+	if category != "" {
+		parts = append(parts, vectordb.FieldCategory+" == "+strconv.Quote(category))
+	}
+	if excludeCase != 0 {
+		parts = append(parts, vectordb.FieldCaseID+" != "+strconv.Itoa(int(excludeCase)))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// validCaseContent 校验案例内容不超过向量集合的字段长度，超长时返回 400
+func validCaseContent(c *gin.Context, content string) bool {
+	if len(content) > vectordb.MaxContentBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": vectordb.ErrContentTooLong.Error()})
+		return false
+	}
+	return true
+}
+
+// syncCase 将案例写入 Milvus 并记录新的向量 ID
+// 失败时把 vector_id 置 0，对账任务会重新写入，并清理该案例残留的旧向量
+func syncCase(ctx context.Context, db *gorm.DB, store *vectordb.CaseStore, kase *common.Case) error {
+	if store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, caseSyncTimeout)
+	defer cancel()
+
+	vectorID, err := store.Upsert(ctx, kase)
+	if err != nil {
+		log.Printf("同步案例向量失败 (case=%d): %v", kase.ID, err)
+		vectorID = 0
+	}
+	kase.VectorID = vectorID
+	if dbErr := db.Model(kase).UpdateColumn("vector_id", vectorID).Error; dbErr != nil && err == nil {
+		err = dbErr
+	}
+	return err
+}
+
+// reconcileCases 修复 MySQL 案例库与 Milvus 案例集合之间的不一致
+// 删除不属于任何有效案例 (已删除或已被新向量取代) 的向量，并为缺少向量的案例补写。
+// 先读取 Milvus 再读取 MySQL，读取 Milvus 之后才写入的向量不会被误删；
+// 但读取时可能有案例正在同步 (向量已写入而 vector_id 尚未回写)，
+// 因此 caseReconcileGrace 内修改过的案例既不清理其向量也不补写，留到下一轮对账
+func reconcileCases(ctx context.Context, db *gorm.DB, store *vectordb.CaseStore) (upserted, deleted int, err error) {
+	entries, err := store.Entries(ctx, "case_id > 0")
+	if err != nil {
+		return 0, 0, err
+	}
+	var cases []common.Case
+	if err := db.Find(&cases).Error; err != nil {
+		return 0, 0, err
+	}
+
+	settled := time.Now().Add(-caseReconcileGrace)
+	live := make(map[int64]*common.Case, len(cases)) // 案例 ID -> 案例
+	for i := range cases {
+		live[int64(cases[i].ID)] = &cases[i]
+	}
+	present := make(map[int64]bool, len(entries))
+	var orphans []int64
+	for _, e := range entries {
+		kase, ok := live[e.CaseID]
+		if ok && kase.VectorID == e.VectorID {
+			present[e.VectorID] = true
+			continue
+		}
+		if ok && kase.UpdatedAt.After(settled) {
+			continue
+		}
+		orphans = append(orphans, e.VectorID)
+	}
+	if err := store.Delete(ctx, orphans...); err != nil {
+		return 0, 0, err
+	}
+	deleted = len(orphans)
+
+	for i := range cases {
+		if cases[i].VectorID != 0 && present[cases[i].VectorID] || cases[i].UpdatedAt.After(settled) {
+			continue
+		}
+		// 内容超长的案例 (如早于长度校验创建的案例) 无法写入，不再反复重试
+		if len(cases[i].Content) > vectordb.MaxContentBytes {
+			continue
+		}
+		if err := syncCase(ctx, db, store, &cases[i]); err != nil {
+			continue
+		}
+		upserted++
+	}
+	return upserted, deleted, nil
+}
+
+// runCaseReconciler 定期执行案例对账
+func runCaseReconciler(db *gorm.DB, nc *nats.Conn, store *vectordb.CaseStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		upserted, deleted, err := reconcileCases(context.Background(), db, store)
+		if err != nil {
+			log.Printf("案例对账失败: %v", err)
+			continue
+		}
+		if upserted > 0 || deleted > 0 {
+			log.Printf("案例对账完成: 补写 %d 条, 清理 %d 条", upserted, deleted)
+			publishPolicyChanged(nc, "case", "")
+		}
+	}
+}
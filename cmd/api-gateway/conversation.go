@@ -0,0 +1,119 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+)
+
+// conversationLimit 单个会话最多保留的消息数
+const conversationLimit = 50
+
+// conversationKey 返回会话在存储中的键
+// conversation_id 由调用方提供，按应用区分，避免不同应用使用相同 ID 时互相读到对方的消息
+func conversationKey(appID, conversationID string) string {
+	return appID + "/" + conversationID
+}
+
+// ConversationStore 保存 IM 会话的最近消息，conversationID 为 conversationKey 生成的键
+// 默认实现为进程内存储，多实例部署时可替换为 Redis 等共享存储
+type ConversationStore interface {
+	// Recent 返回会话最近的 n 条消息 (按时间顺序)
+	Recent(conversationID string, n int) []*safeflow.ChatMessage
+	// Append 追加一条消息
+	Append(conversationID string, msg *safeflow.ChatMessage)
+}
+
+// conversation 是内存存储中的一个会话
+type conversation struct {
+	id        string
+	messages  []*safeflow.ChatMessage
+	updatedAt time.Time
+}
+
+// MemoryConversationStore 是有界的内存会话存储
+// 按最近活跃时间淘汰: 超过 maxConversations 时淘汰最久未活跃的会话，超过 ttl 未活跃的会话视为过期
+type MemoryConversationStore struct {
+	mu               sync.Mutex
+	maxConversations int
+	ttl              time.Duration
+	ll               *list.List // 按活跃时间排序，队首为最近活跃
+	items            map[string]*list.Element
+}
+
+// NewMemoryConversationStore 创建内存会话存储
+func NewMemoryConversationStore(maxConversations int, ttl time.Duration) *MemoryConversationStore {
+	return &MemoryConversationStore{
+		maxConversations: maxConversations,
+		ttl:              ttl,
+		ll:               list.New(),
+		items:            make(map[string]*list.Element),
+	}
+}
+
+// Recent 返回会话最近的 n 条消息
+func (s *MemoryConversationStore) Recent(conversationID string, n int) []*safeflow.ChatMessage {
+	if n <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[conversationID]
+	if !ok {
+		return nil
+	}
+	conv := el.Value.(*conversation)
+	if s.expired(conv) {
+		s.remove(el)
+		return nil
+	}
+	msgs := conv.messages
+	if len(msgs) > n {
+		msgs = msgs[len(msgs)-n:]
+	}
+	out := make([]*safeflow.ChatMessage, len(msgs))
+	copy(out, msgs)
+	return out
+}
+
+// Append 追加一条消息，并刷新会话的活跃时间
+func (s *MemoryConversationStore) Append(conversationID string, msg *safeflow.ChatMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if el, ok := s.items[conversationID]; ok {
+		conv := el.Value.(*conversation)
+		if s.expired(conv) {
+			conv.messages = nil
+		}
+		conv.messages = append(conv.messages, msg)
+		if len(conv.messages) > conversationLimit {
+			conv.messages = append([]*safeflow.ChatMessage(nil), conv.messages[len(conv.messages)-conversationLimit:]...)
+		}
+		conv.updatedAt = now
+		s.ll.MoveToFront(el)
+		return
+	}
+
+	s.items[conversationID] = s.ll.PushFront(&conversation{
+		id:        conversationID,
+		messages:  []*safeflow.ChatMessage{msg},
+		updatedAt: now,
+	})
+	for s.maxConversations > 0 && s.ll.Len() > s.maxConversations {
+		s.remove(s.ll.Back())
+	}
+}
+
+func (s *MemoryConversationStore) expired(conv *conversation) bool {
+	return s.ttl > 0 && time.Since(conv.updatedAt) > s.ttl
+}
+
+func (s *MemoryConversationStore) remove(el *list.Element) {
+	s.ll.Remove(el)
+	delete(s.items, el.Value.(*conversation).id)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+)
+
+func TestConversationStoreIsolatesApps(t *testing.T) {
+	s := NewMemoryConversationStore(10, time.Hour)
+	s.Append(conversationKey("app-a", "c1"), &safeflow.ChatMessage{Role: "user", Content: "a1"})
+	s.Append(conversationKey("app-a", "c1"), &safeflow.ChatMessage{Role: "user", Content: "a2"})
+
+	tests := []struct {
+		name  string
+		appID string
+		want  []string
+	}{
+		{"同一应用读取自己的历史", "app-a", []string{"a1", "a2"}},
+		{"其他应用使用相同会话 ID 读不到", "app-b", nil},
+		{"未携带 API Key 的调用方读不到", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Recent(conversationKey(tt.appID, "c1"), 10)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Recent() = %d messages, want %v", len(got), tt.want)
+			}
+			for i, m := range got {
+				if m.Content != tt.want[i] {
+					t.Fatalf("Recent()[%d] = %s, want %s", i, m.Content, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConversationStoreLimits(t *testing.T) {
+	s := NewMemoryConversationStore(2, time.Hour)
+	for _, id := range []string{"c1", "c2", "c3"} {
+		s.Append(conversationKey("app", id), &safeflow.ChatMessage{Content: id})
+	}
+	if got := s.Recent(conversationKey("app", "c1"), 10); got != nil {
+		t.Fatalf("最久未活跃的会话应被淘汰, got %v", got)
+	}
+	for i := 0; i < conversationLimit+5; i++ {
+		s.Append(conversationKey("app", "c3"), &safeflow.ChatMessage{Content: "m"})
+	}
+	if got := len(s.Recent(conversationKey("app", "c3"), 1000)); got != conversationLimit {
+		t.Fatalf("len(Recent) = %d, want %d", got, conversationLimit)
+	}
+	if got := len(s.Recent(conversationKey("app", "c3"), 3)); got != 3 {
+		t.Fatalf("len(Recent(3)) = %d, want 3", got)
+	}
+}
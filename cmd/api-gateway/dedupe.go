@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/milvus-io/milvus/client/v2/entity"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"github.com/safeflow-project/safeflow/internal/vectordb"
+	"gorm.io/gorm"
+)
+
+const (
+	// dedupeBatch 近似重复检测时每批检索的案例数
+	dedupeBatch = 100
+	// dedupeTopK 每个案例检索的相似案例数
+	dedupeTopK = 5
+)
+
+// casePair 一对案例 (a < b)
+type casePair struct{ a, b uint }
+
+func newCasePair(x, y uint) casePair {
+	if x > y {
+		x, y = y, x
+	}
+	return casePair{x, y}
+}
+
+// registerCaseIssueRoutes 注册案例质量管理 API: 执行去重任务、查询和处理发现的问题
+// 去重任务只比较同一应用内的案例，但会处理所有应用，只允许系统管理员执行；问题按应用查询
+func registerCaseIssueRoutes(admin, system *gin.RouterGroup, db *gorm.DB, store *vectordb.CaseStore, threshold float64) {
+	// 立即执行一次去重任务，返回各类问题的数量
+	system.POST("/cases/dedupe", func(c *gin.Context) {
+		counts, err := dedupeCases(c.Request.Context(), db, store, threshold)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, counts)
+		c.JSON(http.StatusOK, counts)
+	})
+
+	// 查询案例问题 (默认只返回待处理的)，附带问题案例对的内容便于比对
+	admin.GET("/cases/issues", func(c *gin.Context) {
+		query := scoped(db, c).Order("type, case_id, other_case_id").Where("status = ?", c.DefaultQuery("status", common.CaseIssueOpen))
+		if typ := c.Query("type"); typ != "" {
+			query = query.Where("type = ?", typ)
+		}
+		var issues []common.CaseIssue
+		if err := query.Find(&issues).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ids := make([]uint, 0, len(issues)*2)
+		for _, i := range issues {
+			ids = append(ids, i.CaseID, i.OtherCaseID)
+		}
+		var cases []common.Case
+		if len(ids) > 0 {
+			db.Find(&cases, ids)
+		}
+		byID := make(map[uint]common.Case, len(cases))
+		for _, kase := range cases {
+			byID[kase.ID] = kase
+		}
+
+		data := make([]gin.H, 0, len(issues))
+		for _, i := range issues {
+			data = append(data, gin.H{"issue": i, "case": byID[i.CaseID], "other_case": byID[i.OtherCaseID]})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": data})
+	})
+
+	// 修改问题状态: 标记为 ignored 后去重任务不再报告该案例对；清理案例后下次去重时问题自动消失
+	admin.PUT("/cases/issues/:id", func(c *gin.Context) {
+		var issue common.CaseIssue
+		if err := scoped(db, c).First(&issue, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issue not found"})
+			return
+		}
+		var body struct {
+			Status string `json:"status" binding:"required,oneof=open ignored"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		recordBefore(c, issue)
+		issue.Status = body.Status
+		if err := db.Save(&issue).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, issue)
+		c.JSON(http.StatusOK, issue)
+	})
+}
+
+// dedupeCases 执行去重任务: 用本次发现的问题替换所有待处理问题，已忽略的同类问题不再标记
+func dedupeCases(ctx context.Context, db *gorm.DB, store *vectordb.CaseStore, threshold float64) (map[string]int, error) {
+	found, err := findCaseIssues(ctx, db, store, threshold)
+	if err != nil {
+		return nil, err
+	}
+	var ignored []common.CaseIssue
+	if err := db.Where("status = ?", common.CaseIssueIgnored).Find(&ignored).Error; err != nil {
+		return nil, err
+	}
+	for _, i := range ignored {
+		p := newCasePair(i.CaseID, i.OtherCaseID)
+		if issue, ok := found[p]; ok && issue.Type == i.Type {
+			delete(found, p)
+		}
+	}
+
+	issues := make([]common.CaseIssue, 0, len(found))
+	counts := map[string]int{
+		common.CaseIssueDuplicate:     0,
+		common.CaseIssueNearDuplicate: 0,
+		common.CaseIssueContradiction: 0,
+	}
+	for _, issue := range found {
+		issues = append(issues, *issue)
+		counts[issue.Type]++
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ?", common.CaseIssueOpen).Delete(&common.CaseIssue{}).Error; err != nil {
+			return err
+		}
+		if len(issues) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(issues, 100).Error
+	})
+	return counts, err
+}
+
+// findCaseIssues 检查案例库中同一应用内 (全局案例视为一个应用) 内容相同 (按内容哈希) 或向量高度相似的案例对
+// 标签一致的为重复，标签不同的为矛盾
+func findCaseIssues(ctx context.Context, db *gorm.DB, store *vectordb.CaseStore, threshold float64) (map[casePair]*common.CaseIssue, error) {
+	var cases []common.Case
+	if err := db.Find(&cases).Error; err != nil {
+		return nil, err
+	}
+
+	issues := make(map[casePair]*common.CaseIssue)
+	add := func(x, y *common.Case, score float32, typ string) {
+		p := newCasePair(x.ID, y.ID)
+		if _, ok := issues[p]; ok {
+			return
+		}
+		if x.Label != y.Label {
+			typ = common.CaseIssueContradiction
+		}
+		issues[p] = &common.CaseIssue{AppID: x.AppID, CaseID: p.a, OtherCaseID: p.b, Type: typ, Score: score, Status: common.CaseIssueOpen}
+	}
+
+	// 1. 内容相同
+	type hashKey struct{ appID, hash string }
+	byID := make(map[uint]*common.Case, len(cases))
+	byHash := make(map[hashKey][]*common.Case)
+	for i := range cases {
+		kase := &cases[i]
+		byID[kase.ID] = kase
+		key := hashKey{kase.AppID, vectordb.ContentHash(kase.Content)}
+		byHash[key] = append(byHash[key], kase)
+	}
+	for _, group := range byHash {
+		for i := 1; i < len(group); i++ {
+			for j := 0; j < i; j++ {
+				add(group[j], group[i], 1, common.CaseIssueDuplicate)
+			}
+		}
+	}
+
+	// 2. 向量高度相似 (只适用于越大越相似的 COSINE/IP 度量)，直接使用集合中已存储的向量检索
+	if store == nil || threshold <= 0 {
+		return issues, nil
+	}
+	if store.Metric() == entity.L2 {
+		log.Printf("案例集合使用 L2 度量，去重任务跳过近似重复检测")
+		return issues, nil
+	}
+	byApp := make(map[string][]*common.Case)
+	for i := range cases {
+		if cases[i].VectorID != 0 {
+			byApp[cases[i].AppID] = append(byApp[cases[i].AppID], &cases[i])
+		}
+	}
+	for appID, synced := range byApp {
+		if err := findNearDuplicates(ctx, store, appID, synced, byID, threshold, add); err != nil {
+			return nil, err
+		}
+	}
+	return issues, nil
+}
+
+// findNearDuplicates 以一个应用已同步案例的向量检索该应用内的相似案例，相似度不低于阈值的案例对交给 add
+func findNearDuplicates(ctx context.Context, store *vectordb.CaseStore, appID string, synced []*common.Case, byID map[uint]*common.Case, threshold float64, add func(x, y *common.Case, score float32, typ string)) error {
+	filter := vectordb.FieldCaseID + " > 0 and " + vectordb.FieldAppID + " == " + strconv.Quote(appID)
+	for start := 0; start < len(synced); start += dedupeBatch {
+		batch := synced[start:min(start+dedupeBatch, len(synced))]
+		ids := make([]int64, len(batch))
+		for i, kase := range batch {
+			ids[i] = kase.VectorID
+		}
+		vectors, err := store.Vectors(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		var owners []*common.Case
+		var queries [][]float32
+		for _, kase := range batch {
+			if v, ok := vectors[kase.VectorID]; ok {
+				owners = append(owners, kase)
+				queries = append(queries, v)
+			}
+		}
+		results, err := store.SearchVectors(ctx, queries, dedupeTopK+1, filter)
+		if err != nil {
+			return err
+		}
+		for i, hits := range results {
+			for _, h := range hits {
+				other, ok := byID[uint(h.CaseID)]
+				// 只比较案例当前的向量，已被取代的旧向量由对账任务清理
+				if !ok || other.ID == owners[i].ID || other.VectorID != h.VectorID || float64(h.Score) < threshold {
+					continue
+				}
+				add(owners[i], other, h.Score, common.CaseIssueNearDuplicate)
+			}
+		}
+	}
+	return nil
+}
+
+// runCaseDeduper 定期执行去重任务
+func runCaseDeduper(db *gorm.DB, store *vectordb.CaseStore, threshold float64, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		counts, err := dedupeCases(context.Background(), db, store, threshold)
+		if err != nil {
+			log.Printf("案例去重失败: %v", err)
+			continue
+		}
+		log.Printf("案例去重完成: 重复 %d 对, 近似重复 %d 对, 矛盾 %d 对",
+			counts[common.CaseIssueDuplicate], counts[common.CaseIssueNearDuplicate], counts[common.CaseIssueContradiction])
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+// registerEntityRoutes 注册敏感实体词典管理 API
+// 词典供 llm-agent 的 check_political_entities 工具使用，变更后广播策略变更事件
+// 应用的词条只用于该应用的请求，全局词条对所有应用生效
+func registerEntityRoutes(admin *gin.RouterGroup, db *gorm.DB, nc *nats.Conn) {
+	admin.GET("/entities", func(c *gin.Context) {
+		var entities []common.SensitiveEntity
+		query := scoped(db, c).Order("severity desc, id")
+		if category := c.Query("category"); category != "" {
+			query = query.Where("category = ?", category)
+		}
+		if keyword := c.Query("keyword"); keyword != "" {
+			like := "%" + keyword + "%"
+			query = query.Where("name LIKE ? OR aliases LIKE ? OR pinyin LIKE ?", like, like, like)
+		}
+		query.Find(&entities)
+		c.JSON(http.StatusOK, entities)
+	})
+
+	admin.POST("/entities", func(c *gin.Context) {
+		entity := common.SensitiveEntity{IsEnabled: true}
+		if err := c.ShouldBindJSON(&entity); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entity.ID, entity.AppID = 0, tenantOf(c)
+		if err := db.Create(&entity).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, entity)
+		publishPolicyChanged(nc, "entity", "entity:"+strconv.Itoa(int(entity.ID)))
+		c.JSON(http.StatusCreated, entity)
+	})
+
+	// 批量导入词条
+	admin.POST("/entities/import", func(c *gin.Context) {
+		var items []json.RawMessage
+		if err := c.ShouldBindJSON(&items); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "entities is empty"})
+			return
+		}
+		entities := make([]common.SensitiveEntity, len(items))
+		for i, item := range items {
+			// 未指定 is_enabled 的词条默认启用
+			entities[i].IsEnabled = true
+			if err := json.Unmarshal(item, &entities[i]); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "entities[" + strconv.Itoa(i) + "]: " + err.Error()})
+				return
+			}
+			entities[i].ID, entities[i].AppID = 0, tenantOf(c)
+		}
+		if err := db.CreateInBatches(&entities, 100).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, entities)
+		publishPolicyChanged(nc, "entity", "")
+		c.JSON(http.StatusCreated, gin.H{"imported": len(entities)})
+	})
+
+	admin.PUT("/entities/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var entity common.SensitiveEntity
+		if err := scoped(db, c).First(&entity, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Entity not found"})
+			return
+		}
+		recordBefore(c, entity)
+		origID := entity.ID
+		if err := c.ShouldBindJSON(&entity); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entity.ID, entity.AppID = origID, tenantOf(c)
+		if err := db.Save(&entity).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, entity)
+		publishPolicyChanged(nc, "entity", "entity:"+id)
+		c.JSON(http.StatusOK, entity)
+	})
+
+	admin.DELETE("/entities/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var entity common.SensitiveEntity
+		if err := scoped(db, c).First(&entity, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Entity not found"})
+			return
+		}
+		recordBefore(c, entity)
+		if err := db.Delete(&entity).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		publishPolicyChanged(nc, "entity", "entity:"+id)
+		c.Status(http.StatusNoContent)
+	})
+}
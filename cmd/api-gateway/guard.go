@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/safeflow-project/safeflow/internal/common"
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+	"github.com/safeflow-project/safeflow/kitex_gen/safeflow/llmagentservice"
+	"github.com/safeflow-project/safeflow/kitex_gen/safeflow/ruleengineservice"
+)
+
+// registerGuardRoutes 注册大模型输出护栏 API
+// 请求携带完整对话和待审核消息的下标，结论可能为 allow、block 或 rewrite (附带安全替换文本)
+func registerGuardRoutes(r gin.IRoutes, ruleClient ruleengineservice.Client, llmClient llmagentservice.Client, nc *nats.Conn, limits *submitLimits, quotas *quotaTracker) {
+	r.POST("/submit/guard", func(c *gin.Context) {
+		var reqBody struct {
+			Messages    []safeflow.ChatMessage `json:"messages" binding:"required"`
+			TargetIndex *int                   `json:"target_index"` // 待审核消息下标 (可选，默认最后一条)
+			UserID      string                 `json:"user_id"`
+			Scene       string                 `json:"scene"`
+			Language    string                 `json:"language"`
+		}
+		if err := c.ShouldBindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(reqBody.Messages) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "messages 不能为空"})
+			return
+		}
+		for i, msg := range reqBody.Messages {
+			switch msg.Role {
+			case "system", "user", "assistant":
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的消息角色 (messages[" + strconv.Itoa(i) + "]): " + msg.Role})
+				return
+			}
+		}
+		target := len(reqBody.Messages) - 1
+		if reqBody.TargetIndex != nil {
+			target = *reqBody.TargetIndex
+		}
+		if target < 0 || target >= len(reqBody.Messages) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_index 超出范围"})
+			return
+		}
+		if !limits.admit(c, reqBody.UserID, 1) {
+			return
+		}
+
+		conversation := make([]*safeflow.ChatMessage, len(reqBody.Messages))
+		for i := range reqBody.Messages {
+			conversation[i] = &reqBody.Messages[i]
+		}
+
+		requestID := uuid.New().String()
+		ctx := context.Background()
+
+		// 规则引擎只审核目标消息本身，Agent 结合整段对话判断
+		scanReq := &safeflow.ScanRequest{
+			RequestId:    requestID,
+			UserId:       reqBody.UserID,
+			Content:      reqBody.Messages[target].Content,
+			Scene:        reqBody.Scene,
+			Language:     reqBody.Language,
+			Mode:         common.ScanModeOutputGuard,
+			AppId:        c.GetString(appIDKey),
+			Conversation: conversation,
+			TargetIndex:  int32(target),
+		}
+
+		publishAudit := func(resp *safeflow.ScanResponse) {
+			event := common.ContentResultEvent{
+				RequestID:  resp.RequestId,
+				UserID:     reqBody.UserID,
+				Action:     resp.Action,
+				Reason:     resp.Reason,
+				Source:     resp.Source,
+				AppID:      scanReq.AppId,
+				Content:    scanReq.Content,
+				Category:   resp.Category,
+				Confidence: resp.Confidence,
+				Timestamp:  time.Now(),
+			}
+			data, _ := json.Marshal(event)
+			nc.Publish(common.SubjectContentResult, data)
+		}
+
+		ruleResp, err := ruleClient.Scan(ctx, scanReq)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "规则引擎服务错误: " + err.Error()})
+			return
+		}
+		if ruleResp.Action == "block" {
+			publishAudit(ruleResp)
+			c.JSON(http.StatusOK, ruleResp)
+			return
+		}
+
+		if ok, wait := quotas.reserve(scanReq.AppId); !ok {
+			tooManyRequests(c, "今日 LLM 调用配额已用完", wait)
+			return
+		}
+		llmResp, err := llmClient.Scan(ctx, scanReq)
+		if err != nil {
+			// 输出护栏无法确认安全时不放行，降级为拦截，拦截同样写入审计日志
+			fallback := &safeflow.ScanResponse{
+				RequestId: requestID,
+				Action:    "block",
+				Reason:    "LLM 服务暂时不可用: " + err.Error(),
+				Source:    "gateway",
+			}
+			publishAudit(fallback)
+			c.JSON(http.StatusOK, fallback)
+			return
+		}
+
+		publishAudit(llmResp)
+		c.JSON(http.StatusOK, llmResp)
+	})
+}
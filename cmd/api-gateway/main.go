@@ -3,15 +3,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cloudwego/kitex/client"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
 	"github.com/safeflow-project/safeflow/internal/common"
+	"github.com/safeflow-project/safeflow/internal/vectordb"
 	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
 	"github.com/safeflow-project/safeflow/kitex_gen/safeflow/llmagentservice"
 	"github.com/safeflow-project/safeflow/kitex_gen/safeflow/ruleengineservice"
@@ -20,6 +24,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// maxBatchSize 批量审核单次最多提交的内容条数
+const maxBatchSize = 100
+
 func main() {
 	// 1. 加载配置
 	cfg, err := common.LoadConfig()
@@ -47,9 +54,14 @@ func main() {
 	if err != nil {
 		logger.Fatal("连接 MySQL 失败", zap.Error(err))
 	}
+	// 自动迁移管理 API 使用的表
+	db.AutoMigrate(&common.PromptTemplate{}, &common.PolicyVersion{}, &common.SensitiveEntity{}, &common.Case{}, &common.CaseIssue{}, &common.AuditLog{}, &common.Application{}, &common.APIKey{}, &common.AdminUser{}, &common.OperationLog{}, &common.QuotaUsage{}, &common.ScanResult{})
+	if err := ensureInitialAdmin(db, cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		logger.Error("创建初始管理员失败", zap.Error(err))
+	}
 
-	// 3. 初始化 NATS (用于发布审核审计日志)
-	nc, _, err := common.InitNATS(cfg.NatsURL)
+	// 3. 初始化 NATS (用于发布审核审计日志，JetStream 用于异步提交的任务队列)
+	nc, js, err := common.InitNATS(cfg.NatsURL)
 	if err != nil {
 		logger.Fatal("连接 NATS 失败", zap.Error(err))
 	}
@@ -68,102 +80,145 @@ func main() {
 		logger.Fatal("初始化 LLM 客户端失败", zap.Error(err))
 	}
 
+	// 案例向量库 (用于同步案例库)，不可用时案例只写入 MySQL，恢复后由对账任务补齐
+	caseStore, err := vectordb.NewCaseStore(context.Background(), cfg)
+	if err == nil {
+		// 集合不存在时按统一定义创建；已有集合与定义不一致时不同步，避免写入无法检索的向量
+		if err = caseStore.EnsureCollection(context.Background(), false); err != nil {
+			caseStore.Close(context.Background())
+		}
+	}
+	if err != nil {
+		logger.Error("初始化案例向量库失败，案例将不会同步到 Milvus", zap.Error(err))
+		caseStore = nil
+	} else if cfg.CaseReconcileInterval > 0 {
+		go runCaseReconciler(db, nc, caseStore, cfg.CaseReconcileInterval)
+	}
+	if cfg.CaseDedupeInterval > 0 {
+		go runCaseDeduper(db, caseStore, cfg.CaseDuplicateThreshold, cfg.CaseDedupeInterval)
+	}
+
+	// IM 会话的最近消息 (用于多轮上下文审核)
+	conversations := NewMemoryConversationStore(cfg.ConversationMax, cfg.ConversationTTL)
+
 	// 5. 启动 Gin Web 服务器
 	r := gin.Default()
 
 	// 配置 CORS 中间件 (允许跨域请求)
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+	r.Use(corsMiddleware(cfg.CORSAllowOrigins))
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	// 提交审核的接口需要携带 API Key，Key 确定调用方应用 (app_id)
+	keyAuth := newAPIKeyAuth(db, cfg.APIKeyRequired)
+	api := r.Group("", keyAuth.Middleware())
+
+	// 按 API Key 和 user_id 限流 (令牌桶，按内容条数计)，按应用限制每天的 LLM 调用次数
+	limits := &submitLimits{
+		keys:  newRateLimiter(cfg.RateLimitKeyRPS, cfg.RateLimitKeyBurst),
+		users: newRateLimiter(cfg.RateLimitUserRPS, cfg.RateLimitUserBurst),
+	}
+	quotas := newQuotaTracker(db, cfg.LLMDailyQuota)
+	moderation := &pipeline{ruleClient: ruleClient, llmClient: llmClient, nc: nc, quotas: quotas}
+
+	// 异步提交由工作协程通过 content.submitted 处理，结果保存到数据库供轮询
+	if err := startSubmissionWorkers(js, db, moderation, cfg.AsyncWorkers); err != nil {
+		logger.Error("启动异步审核工作协程失败，本实例不受理异步提交", zap.Error(err))
+	} else {
+		registerAsyncRoutes(api, db, js, limits)
+	}
+	registerResultRoutes(api, db)
 
 	// 定义提交审核的 API 接口
-	r.POST("/submit", func(c *gin.Context) {
+	api.POST("/submit", func(c *gin.Context) {
 		var reqBody struct {
-			Content string `json:"content" binding:"required"`
-			UserID  string `json:"user_id"`
+			Content  string `json:"content" binding:"required"`
+			UserID   string `json:"user_id"`
+			Scene    string `json:"scene"`    // 业务场景 (可选，用于选择提示词模板)
+			Language string `json:"language"` // 内容语言 (可选)
+			Mode     string `json:"mode"`     // 审核模式 (可选): content, prompt_injection
+			// IM 会话 ID (可选)，指定时附带同会话最近的消息一起审核
+			ConversationID string `json:"conversation_id"`
+			IncludeTrace   bool   `json:"include_trace"` // 是否返回 Agent 执行轨迹 (调试用)
 		}
 
 		if err := c.ShouldBindJSON(&reqBody); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if !validScanMode(reqBody.Mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的审核模式: " + reqBody.Mode})
+			return
+		}
+		if !limits.admit(c, reqBody.UserID, 1) {
+			return
+		}
 
 		requestID := uuid.New().String()
 		ctx := context.Background()
 
-		// 步骤 1: 调用规则引擎 (快速初筛)
 		scanReq := &safeflow.ScanRequest{
-			RequestId: requestID,
-			UserId:    reqBody.UserID,
-			Content:   reqBody.Content,
+			RequestId:    requestID,
+			UserId:       reqBody.UserID,
+			Content:      reqBody.Content,
+			Scene:        reqBody.Scene,
+			Language:     reqBody.Language,
+			Mode:         reqBody.Mode,
+			IncludeTrace: reqBody.IncludeTrace,
+			AppId:        c.GetString(appIDKey),
 		}
 
-		ruleResp, err := ruleClient.Scan(ctx, scanReq)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "规则引擎服务错误: " + err.Error()})
-			return
-		}
-
-		// 辅助函数：发布审计日志事件
-		publishAudit := func(resp *safeflow.ScanResponse) {
-			event := common.ContentResultEvent{
-				RequestID: resp.RequestId,
-				UserID:    reqBody.UserID,
-				Action:    resp.Action,
-				Reason:    resp.Reason,
-				Source:    resp.Source,
-				Timestamp: time.Now(),
+		// 附带同一会话最近的消息，当前消息作为待审核的目标
+		if reqBody.ConversationID != "" {
+			key := conversationKey(scanReq.AppId, reqBody.ConversationID)
+			current := &safeflow.ChatMessage{Role: "user", Content: reqBody.Content, UserId: reqBody.UserID}
+			if history := conversations.Recent(key, cfg.ConversationHistory); len(history) > 0 {
+				scanReq.Conversation = append(history, current)
+				scanReq.TargetIndex = int32(len(history))
 			}
-			data, _ := json.Marshal(event)
-			nc.Publish(common.SubjectContentResult, data)
+			defer conversations.Append(key, current)
 		}
 
-		// 如果规则引擎拦截 (Block)，直接返回，不再调用 LLM
-		if ruleResp.Action == "block" {
-			publishAudit(ruleResp)
-			c.JSON(http.StatusOK, ruleResp)
+		resp, err := moderation.scan(ctx, scanReq, reqBody.ConversationID)
+		var quotaErr *quotaExceededError
+		switch {
+		case errors.As(err, &quotaErr):
+			tooManyRequests(c, quotaErr.Error(), quotaErr.wait)
 			return
-		}
-
-		// 步骤 2: 调用 LLM Agent (如果通过了规则引擎)
-		// 这一步耗时较长，涉及大模型推理和工具调用
-		llmResp, err := llmClient.Scan(ctx, scanReq)
-		if err != nil {
-			// 如果 LLM 服务不可用，降级处理为人工审核 (Review)
-			c.JSON(http.StatusOK, gin.H{
-				"request_id": requestID,
-				"action":     "review",
-				"reason":     "LLM 服务暂时不可用: " + err.Error(),
-				"source":     "gateway",
-			})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-
-		// 发布最终结果的审计日志
-		publishAudit(llmResp)
-		c.JSON(http.StatusOK, llmResp)
+		c.JSON(http.StatusOK, resp)
 	})
 
+	// 大模型输出护栏 API (对话 + 目标消息)
+	registerGuardRoutes(api, ruleClient, llmClient, nc, limits, quotas)
+
 	// 批量审核 API
-	r.POST("/submit/batch", func(c *gin.Context) {
+	api.POST("/submit/batch", func(c *gin.Context) {
 		var reqBody struct {
 			BatchID  string   `json:"batch_id"`
 			Contents []string `json:"contents" binding:"required"`
 			UserID   string   `json:"user_id"`
+			Scene    string   `json:"scene"`
+			Language string   `json:"language"`
+			Mode     string   `json:"mode"`
 		}
 		if err := c.ShouldBindJSON(&reqBody); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if !validScanMode(reqBody.Mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的审核模式: " + reqBody.Mode})
+			return
+		}
+		if len(reqBody.Contents) > maxBatchSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "contents 最多 " + strconv.Itoa(maxBatchSize) + " 条"})
+			return
+		}
+		// 批量请求按内容条数计入限流
+		if !limits.admit(c, reqBody.UserID, len(reqBody.Contents)) {
+			return
+		}
 
 		results := make([]interface{}, 0, len(reqBody.Contents))
 		ctx := context.Background()
@@ -171,7 +226,7 @@ func main() {
 		// 简单串行处理 (生产环境应改为并行)
 		for _, content := range reqBody.Contents {
 			reqID := uuid.New().String()
-			scanReq := &safeflow.ScanRequest{RequestId: reqID, UserId: reqBody.UserID, Content: content}
+			scanReq := &safeflow.ScanRequest{RequestId: reqID, UserId: reqBody.UserID, Content: content, Scene: reqBody.Scene, Language: reqBody.Language, Mode: reqBody.Mode, AppId: c.GetString(appIDKey)}
 
 			// 1. Rule Engine
 			ruleResp, err := ruleClient.Scan(ctx, scanReq)
@@ -184,7 +239,11 @@ func main() {
 				continue
 			}
 
-			// 2. LLM Agent
+			// 2. LLM Agent (配额用完的内容单独返回错误)
+			if ok, _ := quotas.reserve(scanReq.AppId); !ok {
+				results = append(results, map[string]interface{}{"content": content, "error": "今日 LLM 调用配额已用完"})
+				continue
+			}
 			llmResp, err := llmClient.Scan(ctx, scanReq)
 			if err != nil {
 				results = append(results, map[string]interface{}{"content": content, "error": err.Error()})
@@ -197,71 +256,109 @@ func main() {
 	})
 
 	// 管理 API (Admin) - Rule Studio
-	admin := r.Group("/admin")
+	// 通过 /admin/login 登录后携带令牌访问，各路由分组按角色授权，所有修改操作记入操作日志；
+	// 策略按应用隔离，X-App-ID 请求头选择所操作的应用，不携带时操作全局策略
+	signer := newTokenSigner(cfg.AdminTokenSecret, cfg.AdminTokenTTL)
+	registerLoginRoute(r.Group("/admin"), db, signer)
+	admin := r.Group("/admin", adminAuthMiddleware(db, signer), tenantMiddleware(db), operationLogger(db))
+	policy := admin.Group("", requirePermission(permPolicy))
+	review := admin.Group("", requirePermission(permReview))
+	audit := admin.Group("", requirePermission(permAudit))
+	system := admin.Group("", requirePermission(permSystem))
 	{
 		// 规则管理
-		admin.GET("/rules", func(c *gin.Context) {
+		policy.GET("/rules", func(c *gin.Context) {
 			var rules []common.Rule
-			db.Order("priority desc").Find(&rules)
+			scoped(db, c).Order("priority desc").Find(&rules)
 			c.JSON(http.StatusOK, rules)
 		})
-		admin.POST("/rules", func(c *gin.Context) {
+		policy.POST("/rules", func(c *gin.Context) {
 			var rule common.Rule
 			if err := c.ShouldBindJSON(&rule); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			rule.ID, rule.AppID = 0, tenantOf(c)
 			if err := db.Create(&rule).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			recordAfter(c, rule)
+			publishPolicyChanged(nc, "rule", "rule:"+strconv.Itoa(int(rule.ID)))
 			c.JSON(http.StatusCreated, rule)
 		})
-		admin.PUT("/rules/:id", func(c *gin.Context) {
+		policy.PUT("/rules/:id", func(c *gin.Context) {
 			id := c.Param("id")
 			var rule common.Rule
-			if err := db.First(&rule, id).Error; err != nil {
+			if err := scoped(db, c).First(&rule, id).Error; err != nil {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
 				return
 			}
+			recordBefore(c, rule)
+			origID := rule.ID
 			if err := c.ShouldBindJSON(&rule); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			// 规则不能通过修改转移到其他应用
+			rule.ID, rule.AppID = origID, tenantOf(c)
 			db.Save(&rule)
+			recordAfter(c, rule)
+			publishPolicyChanged(nc, "rule", "rule:"+id)
 			c.JSON(http.StatusOK, rule)
 		})
-		admin.DELETE("/rules/:id", func(c *gin.Context) {
+		policy.DELETE("/rules/:id", func(c *gin.Context) {
 			id := c.Param("id")
-			db.Delete(&common.Rule{}, id)
+			var rule common.Rule
+			if err := scoped(db, c).First(&rule, id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+				return
+			}
+			recordBefore(c, rule)
+			db.Delete(&rule)
+			publishPolicyChanged(nc, "rule", "rule:"+id)
 			c.Status(http.StatusNoContent)
 		})
 
-		// 案例库管理 (Case Knowledge Base)
-		admin.GET("/cases", func(c *gin.Context) {
-			var cases []common.Case
-			db.Order("created_at desc").Find(&cases)
-			c.JSON(http.StatusOK, cases)
+		// 案例库管理 (Case Knowledge Base)，与 Milvus 案例集合保持同步
+		registerCaseRoutes(policy, system, db, nc, caseStore)
+
+		// 案例质量管理 (重复、近似重复与标签矛盾的案例)
+		registerCaseIssueRoutes(policy, system, db, caseStore, cfg.CaseDuplicateThreshold)
+
+		// 敏感实体词典管理
+		registerEntityRoutes(policy, db, nc)
+
+		// 提示词模板管理
+		registerPromptRoutes(policy, db, nc)
+
+		// 应用与 API Key 管理
+		registerAppRoutes(system, db, keyAuth, quotas)
+
+		// 管理员账号与操作日志
+		registerUserRoutes(admin, system, db, signer)
+		registerOperationLogRoutes(system, db)
+
+		// 审核结果复核与案例沉淀
+		registerReviewRoutes(review, db, nc, caseStore, autoPromoteRule{
+			enabled:       cfg.CaseAutoPromote,
+			minConfidence: cfg.CaseAutoPromoteConfidence,
 		})
-		admin.POST("/cases", func(c *gin.Context) {
-			var kase common.Case
-			if err := c.ShouldBindJSON(&kase); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
-			// 这里应该同时调用 Milvus 插入向量，简化起见仅存 MySQL
-			kase.IsCustom = true
-			if err := db.Create(&kase).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+		// Agent 运行指标 (缓存命中率等)
+		system.GET("/agent/stats", func(c *gin.Context) {
+			stats, err := llmClient.Stats(context.Background(), &safeflow.StatsRequest{})
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "LLM 服务错误: " + err.Error()})
 				return
 			}
-			c.JSON(http.StatusCreated, kase)
+			c.JSON(http.StatusOK, stats.Metrics)
 		})
 
 		// 审计日志中心
-		admin.GET("/audits", func(c *gin.Context) {
+		audit.GET("/audits", func(c *gin.Context) {
 			var audits []common.AuditLog
-			query := db.Model(&common.AuditLog{}).Order("created_at desc")
+			query := auditScope(db, c).Model(&common.AuditLog{}).Order("created_at desc")
 
 			if uid := c.Query("user_id"); uid != "" {
 				query = query.Where("user_id = ?", uid)
@@ -272,6 +369,20 @@ func main() {
 			if source := c.Query("source"); source != "" {
 				query = query.Where("source = ?", source)
 			}
+			if appID := c.Query("app_id"); appID != "" {
+				query = query.Where("app_id = ?", appID)
+			}
+			if convID := c.Query("conversation_id"); convID != "" {
+				query = query.Where("conversation_id = ?", convID)
+			}
+			// reviewed=true/false 按是否已人工复核筛选，便于复核人领取待复核记录
+			if reviewed := c.Query("reviewed"); reviewed != "" {
+				if reviewed == "true" {
+					query = query.Where("review_label <> ''")
+				} else {
+					query = query.Where("review_label = '' OR review_label IS NULL")
+				}
+			}
 
 			page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 			pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
@@ -288,14 +399,45 @@ func main() {
 			})
 		})
 
+		// 查询 Agent 执行轨迹 (模型调用、工具调用、耗时与 Token 用量)
+		audit.GET("/traces/:request_id", func(c *gin.Context) {
+			var traces []common.AgentTrace
+			if err := auditScope(db, c).Where("request_id = ?", c.Param("request_id")).Order("created_at asc").Find(&traces).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if len(traces) == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": "未找到执行轨迹"})
+				return
+			}
+
+			data := make([]gin.H, 0, len(traces))
+			for _, t := range traces {
+				data = append(data, gin.H{
+					"id":           t.ID,
+					"request_id":   t.RequestID,
+					"app_id":       t.AppID,
+					"steps":        json.RawMessage(t.Steps),
+					"total_tokens": t.TotalTokens,
+					"latency_ms":   t.LatencyMs,
+					"created_at":   t.CreatedAt,
+				})
+			}
+			c.JSON(http.StatusOK, gin.H{"data": data})
+		})
+
+		// 查询各应用的 LLM 调用配额用量
+		registerQuotaRoutes(audit, db, quotas, cfg)
+
 		// 版本管理 (快照)
-		admin.POST("/versions/snapshot", func(c *gin.Context) {
-			// 简单实现：将当前启用的规则导出为 JSON 并保存
+		policy.POST("/versions/snapshot", func(c *gin.Context) {
+			// 简单实现：将当前应用 (或全局) 启用的规则导出为 JSON 并保存
 			var rules []common.Rule
-			db.Where("is_enabled = ?", true).Find(&rules)
+			scoped(db, c).Where("is_enabled = ?", true).Find(&rules)
 
 			configBytes, _ := json.Marshal(rules)
 			version := common.PolicyVersion{
+				AppID:     tenantOf(c),
 				Version:   time.Now().Format("v20060102150405"),
 				Type:      "rule",
 				Config:    string(configBytes),
@@ -306,6 +448,8 @@ func main() {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			recordAfter(c, version)
+			publishPolicyChanged(nc, "snapshot", version.Version)
 			c.JSON(http.StatusCreated, version)
 		})
 	}
@@ -313,3 +457,51 @@ func main() {
 	logger.Info("API 网关正在启动...", zap.String("port", cfg.GatewayPort))
 	r.Run(":" + cfg.GatewayPort)
 }
+
+// publishPolicyChanged 广播策略变更事件
+// 下游服务 (如 llm-agent) 收到后刷新本地策略并清理缓存
+func publishPolicyChanged(nc *nats.Conn, typ, target string) {
+	data, _ := json.Marshal(common.PolicyChangedEvent{
+		Type:      typ,
+		Target:    target,
+		Timestamp: time.Now(),
+	})
+	nc.Publish(common.SubjectPolicyChanged, data)
+}
+
+// corsMiddleware 返回 CORS 中间件，origins 为逗号分隔的允许来源，* 表示任意来源 (此时不允许携带凭证)
+func corsMiddleware(origins string) gin.HandlerFunc {
+	allowed := make(map[string]bool)
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowed[origin] = true
+		}
+	}
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		if origin := c.GetHeader("Origin"); allowed["*"] {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else if origin != "" && allowed[origin] {
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+			header.Add("Vary", "Origin")
+		}
+		header.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, "+apiKeyHeader+", "+tenantHeader)
+		header.Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// validScanMode 检查审核模式是否受支持，空值按 content 处理
+func validScanMode(mode string) bool {
+	switch mode {
+	case "", common.ScanModeContent, common.ScanModePromptInjection:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+// gin 上下文中保存操作日志内容的键
+const (
+	opBeforeKey = "op_before"
+	opAfterKey  = "op_after"
+	opSkipKey   = "op_skip"
+)
+
+// recordBefore 记录修改前的对象，在修改前调用 (立即序列化，后续修改不影响记录)
+func recordBefore(c *gin.Context, v any) {
+	data, _ := json.Marshal(v)
+	c.Set(opBeforeKey, string(data))
+}
+
+// recordAfter 记录修改后的对象
+func recordAfter(c *gin.Context, v any) {
+	data, _ := json.Marshal(v)
+	c.Set(opAfterKey, string(data))
+}
+
+// skipOperationLog 标记当前请求不修改数据 (如使用 POST 的检索接口)，不记录操作日志
+func skipOperationLog(c *gin.Context) {
+	c.Set(opSkipKey, true)
+}
+
+// operationLogger 记录管理后台所有成功的修改操作 (非 GET 请求)：操作人、路由、状态码，以及处理函数记录的修改前后的对象
+// 失败的请求只有在已记录了修改后的对象 (即部分修改已生效) 时才记录
+func operationLogger(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.GetBool(opSkipKey) {
+			return
+		}
+		if c.Writer.Status() >= http.StatusBadRequest && c.GetString(opAfterKey) == "" {
+			return
+		}
+		entry := common.OperationLog{
+			AppID:     tenantOf(c),
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			Before:    c.GetString(opBeforeKey),
+			After:     c.GetString(opAfterKey),
+			CreatedAt: time.Now(),
+		}
+		if user := currentAdmin(c); user != nil {
+			entry.Actor, entry.Role = user.Username, user.Role
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// registerOperationLogRoutes 注册操作日志查询 API，支持按操作人、应用、路由筛选
+func registerOperationLogRoutes(system *gin.RouterGroup, db *gorm.DB) {
+	system.GET("/operations", func(c *gin.Context) {
+		var logs []common.OperationLog
+		query := db.Model(&common.OperationLog{}).Order("created_at desc")
+		if actor := c.Query("actor"); actor != "" {
+			query = query.Where("actor = ?", actor)
+		}
+		if appID := c.Query("app_id"); appID != "" {
+			query = query.Where("app_id = ?", appID)
+		}
+		if route := c.Query("route"); route != "" {
+			query = query.Where("route = ?", route)
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		offset := (page - 1) * pageSize
+
+		var total int64
+		query.Count(&total)
+		query.Limit(pageSize).Offset(offset).Find(&logs)
+
+		c.JSON(http.StatusOK, gin.H{
+			"total": total,
+			"page":  page,
+			"data":  logs,
+		})
+	})
+}
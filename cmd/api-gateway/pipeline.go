@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/safeflow-project/safeflow/internal/common"
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+	"github.com/safeflow-project/safeflow/kitex_gen/safeflow/llmagentservice"
+	"github.com/safeflow-project/safeflow/kitex_gen/safeflow/ruleengineservice"
+)
+
+// quotaExceededError 应用当天的 LLM 调用配额已用完，wait 为距离配额重置的时长
+type quotaExceededError struct {
+	wait time.Duration
+}
+
+func (e *quotaExceededError) Error() string {
+	return "今日 LLM 调用配额已用完"
+}
+
+// pipeline 审核流程: 规则引擎初筛，未拦截的内容交给 LLM Agent
+// 同步提交 (/submit) 与异步提交的工作协程共用
+type pipeline struct {
+	ruleClient ruleengineservice.Client
+	llmClient  llmagentservice.Client
+	nc         *nats.Conn
+	quotas     *quotaTracker
+}
+
+// scan 审核一条内容并发布审计事件，conversationID 随审计事件写入审计日志
+// 规则引擎调用失败时返回错误；配额用完时返回 *quotaExceededError；
+// LLM Agent 不可用时降级为人工复核 (review)，此时不发布审计事件
+func (p *pipeline) scan(ctx context.Context, req *safeflow.ScanRequest, conversationID string) (*safeflow.ScanResponse, error) {
+	// 步骤 1: 调用规则引擎 (快速初筛)
+	ruleResp, err := p.ruleClient.Scan(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("规则引擎服务错误: %w", err)
+	}
+	// 如果规则引擎拦截 (Block)，直接返回，不再调用 LLM
+	if ruleResp.Action == "block" {
+		p.publishAudit(req, ruleResp, conversationID)
+		return ruleResp, nil
+	}
+
+	// 步骤 2: 调用 LLM Agent (如果通过了规则引擎)
+	// 这一步耗时较长，涉及大模型推理和工具调用，受应用每日配额限制
+	if ok, wait := p.quotas.reserve(req.AppId); !ok {
+		return nil, &quotaExceededError{wait: wait}
+	}
+	llmResp, err := p.llmClient.Scan(ctx, req)
+	if err != nil {
+		return &safeflow.ScanResponse{
+			RequestId: req.RequestId,
+			Action:    "review",
+			Reason:    "LLM 服务暂时不可用: " + err.Error(),
+			Source:    "gateway",
+		}, nil
+	}
+
+	// 发布最终结果的审计日志
+	p.publishAudit(req, llmResp, conversationID)
+	return llmResp, nil
+}
+
+// publishAudit 发布审计日志事件
+func (p *pipeline) publishAudit(req *safeflow.ScanRequest, resp *safeflow.ScanResponse, conversationID string) {
+	event := common.ContentResultEvent{
+		RequestID:      resp.RequestId,
+		UserID:         req.UserId,
+		Action:         resp.Action,
+		Reason:         resp.Reason,
+		Source:         resp.Source,
+		AppID:          req.AppId,
+		Timestamp:      time.Now(),
+		ConversationID: conversationID,
+		Content:        req.Content,
+		Category:       resp.Category,
+		Confidence:     resp.Confidence,
+	}
+	data, _ := json.Marshal(event)
+	p.nc.Publish(common.SubjectContentResult, data)
+}
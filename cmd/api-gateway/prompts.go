@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"github.com/nats-io/nats.go"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+// registerPromptRoutes 注册提示词模板管理 API
+// 每次创建或修改模板都会生成新版本，并以 PolicyVersion (Type=model) 记录快照
+// 应用可为场景配置自己的模板，未配置的场景使用全局模板
+func registerPromptRoutes(admin *gin.RouterGroup, db *gorm.DB, nc *nats.Conn) {
+	admin.GET("/prompts", func(c *gin.Context) {
+		var prompts []common.PromptTemplate
+		query := scoped(db, c).Order("scene, updated_at desc")
+		if scene := c.Query("scene"); scene != "" {
+			query = query.Where("scene = ?", scene)
+		}
+		query.Find(&prompts)
+		c.JSON(http.StatusOK, prompts)
+	})
+
+	admin.POST("/prompts", func(c *gin.Context) {
+		var prompt common.PromptTemplate
+		if err := c.ShouldBindJSON(&prompt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validatePrompt(&prompt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		prompt.ID, prompt.AppID = 0, tenantOf(c)
+		prompt.IsActive = false // 需要通过 activate 接口显式生效
+
+		err := savePrompt(db, &prompt, func(tx *gorm.DB) error {
+			return tx.Create(&prompt).Error
+		})
+		if err != nil {
+			promptSaveError(c, err)
+			return
+		}
+		recordAfter(c, prompt)
+		publishPolicyChanged(nc, "prompt", promptTarget(prompt.ID))
+		c.JSON(http.StatusCreated, prompt)
+	})
+
+	admin.PUT("/prompts/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var prompt common.PromptTemplate
+		if err := scoped(db, c).First(&prompt, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+			return
+		}
+		recordBefore(c, prompt)
+		origID, isActive, scene := prompt.ID, prompt.IsActive, prompt.Scene
+		if err := c.ShouldBindJSON(&prompt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validatePrompt(&prompt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// 生效中的模板换场景会让新场景同时存在两个生效模板
+		if isActive && prompt.Scene != scene {
+			c.JSON(http.StatusConflict, gin.H{"error": "生效中的模板不能修改场景"})
+			return
+		}
+		// 生效状态只能通过 activate 接口修改，模板不能转移到其他应用
+		prompt.ID, prompt.IsActive, prompt.AppID = origID, isActive, tenantOf(c)
+
+		err := savePrompt(db, &prompt, func(tx *gorm.DB) error {
+			return tx.Save(&prompt).Error
+		})
+		if err != nil {
+			promptSaveError(c, err)
+			return
+		}
+		recordAfter(c, prompt)
+		publishPolicyChanged(nc, "prompt", promptTarget(prompt.ID))
+		c.JSON(http.StatusOK, prompt)
+	})
+
+	admin.DELETE("/prompts/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var prompt common.PromptTemplate
+		if err := scoped(db, c).First(&prompt, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+			return
+		}
+		recordBefore(c, prompt)
+		if err := db.Delete(&prompt).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		publishPolicyChanged(nc, "prompt", promptTarget(id))
+		c.Status(http.StatusNoContent)
+	})
+
+	// 将模板设为所属应用在该场景的生效模板 (同应用同场景的其他模板自动失效)
+	admin.POST("/prompts/:id/activate", func(c *gin.Context) {
+		id := c.Param("id")
+		var prompt common.PromptTemplate
+		if err := scoped(db, c).First(&prompt, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+			return
+		}
+		recordBefore(c, prompt)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&common.PromptTemplate{}).
+				Where("app_id = ? AND scene = ? AND id <> ?", prompt.AppID, prompt.Scene, prompt.ID).
+				Update("is_active", false).Error; err != nil {
+				return err
+			}
+			return tx.Model(&prompt).Update("is_active", true).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, prompt)
+		publishPolicyChanged(nc, "prompt", promptTarget(prompt.ID))
+		c.JSON(http.StatusOK, prompt)
+	})
+
+	// 查看模板的历史版本
+	admin.GET("/prompts/:id/versions", func(c *gin.Context) {
+		var versions []common.PolicyVersion
+		scoped(db, c).Where("type = ? AND target = ?", "model", promptTarget(c.Param("id"))).
+			Order("created_at desc").Find(&versions)
+		c.JSON(http.StatusOK, versions)
+	})
+
+	// 回滚到指定历史版本 (回滚本身也会生成一个新版本)
+	admin.POST("/prompts/:id/rollback", func(c *gin.Context) {
+		var reqBody struct {
+			Version string `json:"version" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		id := c.Param("id")
+		var prompt common.PromptTemplate
+		if err := scoped(db, c).First(&prompt, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+			return
+		}
+		var version common.PolicyVersion
+		if err := scoped(db, c).Where("type = ? AND target = ? AND version = ?", "model", promptTarget(id), reqBody.Version).
+			First(&version).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		var snapshot common.PromptTemplate
+		if err := json.Unmarshal([]byte(version.Config), &snapshot); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "版本快照损坏: " + err.Error()})
+			return
+		}
+
+		recordBefore(c, prompt)
+		prompt.Name = snapshot.Name
+		prompt.Content = snapshot.Content
+		prompt.Taxonomy = snapshot.Taxonomy
+		prompt.FewShotK = snapshot.FewShotK
+		prompt.Comment = "回滚自 " + reqBody.Version
+		err := savePrompt(db, &prompt, func(tx *gorm.DB) error {
+			return tx.Save(&prompt).Error
+		})
+		if err != nil {
+			promptSaveError(c, err)
+			return
+		}
+		recordAfter(c, prompt)
+		publishPolicyChanged(nc, "prompt", promptTarget(prompt.ID))
+		c.JSON(http.StatusOK, prompt)
+	})
+}
+
+// validatePrompt 校验模板语法并补全默认值
+func validatePrompt(prompt *common.PromptTemplate) error {
+	if prompt.Scene == "" {
+		prompt.Scene = "default"
+	}
+	if prompt.Name == "" {
+		prompt.Name = prompt.Scene
+	}
+	if prompt.FewShotK < 0 || prompt.FewShotK > 10 {
+		return fmt.Errorf("few_shot_k 必须在 0~10 之间")
+	}
+	if _, err := template.New(prompt.Name).Parse(prompt.Content); err != nil {
+		return fmt.Errorf("模板语法错误: %w", err)
+	}
+	return nil
+}
+
+// promptSaveRetries 生成的版本号与并发写入冲突时最多尝试的次数
+const promptSaveRetries = 3
+
+// errPromptVersionConflict 多次重试后版本号仍然冲突
+var errPromptVersionConflict = errors.New("版本号冲突，请稍后重试")
+
+// savePrompt 在事务中为模板生成新版本号，调用 save 写入模板并记录版本快照
+// 并发写入在同一秒内生成相同的版本号时唯一索引冲突，整个事务重新生成版本号后重试
+func savePrompt(db *gorm.DB, prompt *common.PromptTemplate, save func(tx *gorm.DB) error) error {
+	for attempt := 0; attempt < promptSaveRetries; attempt++ {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			version, err := newPromptVersion(tx)
+			if err != nil {
+				return err
+			}
+			prompt.Version = version
+			if err := save(tx); err != nil {
+				return err
+			}
+			return snapshotPrompt(tx, prompt)
+		})
+		if !isDuplicateKey(err) {
+			return err
+		}
+	}
+	return errPromptVersionConflict
+}
+
+// promptSaveError 返回保存模板失败的响应，版本号冲突时返回 409
+func promptSaveError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errPromptVersionConflict) {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// isDuplicateKey 判断是否为 MySQL 唯一索引冲突 (ER_DUP_ENTRY)
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// newPromptVersion 生成基于时间的版本号 (与策略快照格式一致)
+// 同一秒内已有模板版本时追加递增序号 (如 v20240101120000-2)，保证模板的历史版本可以区分
+func newPromptVersion(tx *gorm.DB) (string, error) {
+	base := time.Now().Format("v20060102150405")
+	var taken []string
+	if err := tx.Model(&common.PolicyVersion{}).
+		Where("type = ? AND (version = ? OR version LIKE ?)", "model", base, base+"-%").
+		Pluck("version", &taken).Error; err != nil {
+		return "", err
+	}
+	return nextPromptVersion(base, taken), nil
+}
+
+// nextPromptVersion 返回 base 之后未被占用的版本号，taken 为已存在的 base 及 base-N 版本
+func nextPromptVersion(base string, taken []string) string {
+	last := 0
+	for _, v := range taken {
+		if v == base {
+			last = max(last, 1)
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(v, base+"-")); err == nil && n > last {
+			last = n
+		}
+	}
+	if last == 0 {
+		return base
+	}
+	return base + "-" + strconv.Itoa(last+1)
+}
+
+func promptTarget(id interface{}) string {
+	return fmt.Sprintf("prompt:%v", id)
+}
+
+// snapshotPrompt 将模板当前内容记录为一个策略版本
+func snapshotPrompt(tx *gorm.DB, prompt *common.PromptTemplate) error {
+	configBytes, _ := json.Marshal(prompt)
+	return tx.Create(&common.PolicyVersion{
+		AppID:     prompt.AppID,
+		Version:   prompt.Version,
+		Type:      "model",
+		Target:    promptTarget(prompt.ID),
+		Config:    string(configBytes),
+		Comment:   prompt.Comment,
+		CreatedAt: time.Now(),
+	}).Error
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+func TestNextPromptVersion(t *testing.T) {
+	const base = "v20240101120000"
+	tests := []struct {
+		name  string
+		taken []string
+		want  string
+	}{
+		{"未占用", nil, base},
+		{"已有同秒版本", []string{base}, base + "-2"},
+		{"已有序号版本", []string{base, base + "-2"}, base + "-3"},
+		{"序号按数值递增", []string{base, base + "-2", base + "-10", base + "-9"}, base + "-11"},
+		{"只有序号版本", []string{base + "-3"}, base + "-4"},
+		{"忽略无法解析的后缀", []string{base, base + "-x"}, base + "-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextPromptVersion(base, tt.taken); got != tt.want {
+				t.Fatalf("nextPromptVersion(%v) = %s, want %s", tt.taken, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePrompt(t *testing.T) {
+	tests := []struct {
+		name      string
+		prompt    common.PromptTemplate
+		wantErr   bool
+		wantScene string
+		wantName  string
+	}{
+		{"补全默认场景与名称", common.PromptTemplate{Content: "审核 {{.Scene}}"}, false, "default", "default"},
+		{"名称默认为场景", common.PromptTemplate{Scene: "im", Content: "x"}, false, "im", "im"},
+		{"保留指定名称", common.PromptTemplate{Scene: "im", Name: "私信", Content: "x"}, false, "im", "私信"},
+		{"示例数过大", common.PromptTemplate{Content: "x", FewShotK: 11}, true, "", ""},
+		{"示例数为负", common.PromptTemplate{Content: "x", FewShotK: -1}, true, "", ""},
+		{"模板语法错误", common.PromptTemplate{Content: "{{.Scene"}, true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.prompt
+			err := validatePrompt(&p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validatePrompt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (p.Scene != tt.wantScene || p.Name != tt.wantName) {
+				t.Fatalf("validatePrompt() scene, name = %s, %s, want %s, %s", p.Scene, p.Name, tt.wantScene, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"唯一索引冲突", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, true},
+		{"包装后的唯一索引冲突", fmt.Errorf("save: %w", &mysql.MySQLError{Number: 1062}), true},
+		{"其他 MySQL 错误", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout"}, false},
+		{"非 MySQL 错误", errors.New("boom"), false},
+		{"没有错误", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicateKey(tt.err); got != tt.want {
+				t.Fatalf("isDuplicateKey(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// quotaDayLayout 配额用量按天统计的日期格式 (网关本地时区)
+	quotaDayLayout = "2006-01-02"
+	// quotaCacheTTL 应用配额设置的缓存时间，修改配额在其他网关实例上最多延迟这么久生效
+	quotaCacheTTL = time.Minute
+)
+
+// cachedQuota 一个应用的配额设置
+type cachedQuota struct {
+	limit     int64 // 生效的每日配额，0 表示不限
+	checkedAt time.Time
+}
+
+// quotaTracker 按应用统计并限制每天的 LLM 调用次数
+// 用量保存在数据库中，多个网关实例共享同一份配额
+type quotaTracker struct {
+	db           *gorm.DB
+	defaultLimit int
+
+	mu     sync.Mutex
+	limits map[string]cachedQuota // 应用 ID -> 配额设置
+	rows   map[string]string      // 应用 ID -> 已确认存在用量记录的日期
+}
+
+func newQuotaTracker(db *gorm.DB, defaultLimit int) *quotaTracker {
+	return &quotaTracker{
+		db:           db,
+		defaultLimit: defaultLimit,
+		limits:       make(map[string]cachedQuota),
+		rows:         make(map[string]string),
+	}
+}
+
+// effectiveLimit 返回应用生效的每日配额，0 表示不限
+func (q *quotaTracker) effectiveLimit(app common.Application) int64 {
+	switch {
+	case app.DailyLLMQuota > 0:
+		return int64(app.DailyLLMQuota)
+	case app.DailyLLMQuota < 0 || q.defaultLimit <= 0:
+		return 0
+	default:
+		return int64(q.defaultLimit)
+	}
+}
+
+// limit 查询应用生效的每日配额，结果缓存 quotaCacheTTL
+func (q *quotaTracker) limit(appID string) (int64, error) {
+	now := time.Now()
+	q.mu.Lock()
+	entry, ok := q.limits[appID]
+	q.mu.Unlock()
+	if ok && now.Sub(entry.checkedAt) < quotaCacheTTL {
+		return entry.limit, nil
+	}
+
+	var apps []common.Application
+	if err := q.db.Where("app_id = ?", appID).Limit(1).Find(&apps).Error; err != nil {
+		return 0, err
+	}
+	entry = cachedQuota{limit: q.effectiveLimit(common.Application{}), checkedAt: now}
+	if len(apps) > 0 {
+		entry.limit = q.effectiveLimit(apps[0])
+	}
+
+	q.mu.Lock()
+	q.limits[appID] = entry
+	q.mu.Unlock()
+	return entry.limit, nil
+}
+
+// ensureRow 确保应用当天的用量记录存在
+func (q *quotaTracker) ensureRow(appID, day string) error {
+	q.mu.Lock()
+	known := q.rows[appID] == day
+	q.mu.Unlock()
+	if known {
+		return nil
+	}
+	row := common.QuotaUsage{AppID: appID, Day: day}
+	if err := q.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		return err
+	}
+	q.mu.Lock()
+	q.rows[appID] = day
+	q.mu.Unlock()
+	return nil
+}
+
+// reserve 为应用占用一次当天的 LLM 调用配额，配额用完时返回 false 与距离次日配额重置的时长
+// 未携带 API Key 的请求 (应用 ID 为空) 不受配额限制；数据库异常时放行，不因统计失败影响审核
+func (q *quotaTracker) reserve(appID string) (bool, time.Duration) {
+	if appID == "" {
+		return true, 0
+	}
+	limit, err := q.limit(appID)
+	if err != nil {
+		log.Printf("查询应用 %s 的配额失败: %v", appID, err)
+		return true, 0
+	}
+	now := time.Now()
+	day := now.Format(quotaDayLayout)
+	if err := q.ensureRow(appID, day); err != nil {
+		log.Printf("创建应用 %s 的配额用量记录失败: %v", appID, err)
+		return true, 0
+	}
+
+	// 条件更新保证并发请求和多个网关实例之间不会超额
+	query := q.db.Model(&common.QuotaUsage{}).Where("app_id = ? AND day = ?", appID, day)
+	if limit > 0 {
+		query = query.Where("llm_calls < ?", limit)
+	}
+	result := query.UpdateColumns(map[string]any{"llm_calls": gorm.Expr("llm_calls + 1"), "updated_at": now})
+	if result.Error != nil {
+		log.Printf("更新应用 %s 的配额用量失败: %v", appID, result.Error)
+		return true, 0
+	}
+	if result.RowsAffected == 0 {
+		return false, untilTomorrow(now)
+	}
+	return true, 0
+}
+
+// invalidate 清空配额设置缓存，修改应用配额后立即在本实例生效
+func (q *quotaTracker) invalidate() {
+	q.mu.Lock()
+	q.limits = make(map[string]cachedQuota)
+	q.mu.Unlock()
+}
+
+// untilTomorrow 返回距离次日零点 (配额重置) 的时长
+func untilTomorrow(now time.Time) time.Duration {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
+
+// registerQuotaRoutes 注册配额用量查询 API，返回各应用指定日期 (day，默认当天) 的 LLM 调用次数与剩余配额
+// remaining 为 -1 表示不限
+func registerQuotaRoutes(audit *gin.RouterGroup, db *gorm.DB, quotas *quotaTracker, cfg *common.Config) {
+	audit.GET("/quotas", func(c *gin.Context) {
+		day := c.DefaultQuery("day", time.Now().Format(quotaDayLayout))
+		if _, err := time.Parse(quotaDayLayout, day); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "day 格式错误，应为 YYYY-MM-DD"})
+			return
+		}
+
+		var apps []common.Application
+		query := db.Order("id")
+		if appID := tenantOf(c); appID != "" {
+			query = query.Where("app_id = ?", appID)
+		}
+		query.Find(&apps)
+
+		var usages []common.QuotaUsage
+		db.Where("day = ?", day).Find(&usages)
+		calls := make(map[string]int64, len(usages))
+		for _, u := range usages {
+			calls[u.AppID] = u.LLMCalls
+		}
+
+		data := make([]gin.H, 0, len(apps))
+		for _, app := range apps {
+			limit := quotas.effectiveLimit(app)
+			remaining := int64(-1)
+			if limit > 0 {
+				remaining = max(limit-calls[app.AppID], 0)
+			}
+			data = append(data, gin.H{
+				"app_id":    app.AppID,
+				"name":      app.Name,
+				"day":       day,
+				"llm_calls": calls[app.AppID],
+				"limit":     limit,
+				"remaining": remaining,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"day":  day,
+			"data": data,
+			"rate_limits": gin.H{
+				"key_rps":    cfg.RateLimitKeyRPS,
+				"key_burst":  cfg.RateLimitKeyBurst,
+				"user_rps":   cfg.RateLimitUserRPS,
+				"user_burst": cfg.RateLimitUserBurst,
+			},
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// limiterSweepInterval 清理空闲令牌桶的间隔
+const limiterSweepInterval = time.Minute
+
+// bucket 一个调用方的令牌桶
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter 按键 (API Key、user_id 等) 维护独立的令牌桶，只在本网关实例内生效
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration // 空闲超过该时长的令牌桶已恢复满额，可以删除
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// newRateLimiter 创建每秒补充 rps 个令牌、容量为 burst 的限流器，rps <= 0 时返回 nil (不限流)
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(rps))
+	}
+	idle := time.Duration(float64(burst) / rps * float64(time.Second))
+	if idle < limiterSweepInterval {
+		idle = limiterSweepInterval
+	}
+	return &rateLimiter{
+		limit:     rate.Limit(rps),
+		burst:     burst,
+		idle:      idle,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// fits 返回一次取出 n 个令牌是否可能成功 (n 不超过令牌桶容量)
+func (l *rateLimiter) fits(n int) bool {
+	return l == nil || n <= l.burst
+}
+
+// allow 尝试从 key 的令牌桶中取出 n 个令牌
+// 令牌不足时不消耗令牌，返回 false 与需要等待的时长；n 超过容量时总是返回 false，调用方应先用 fits 检查
+func (l *rateLimiter) allow(key string, n int) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > limiterSweepInterval {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > l.idle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, n)
+	if !r.OK() {
+		return false, time.Second
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// submitLimits 提交审核接口的限流: 按调用方 (API Key) 和终端用户 (user_id) 分别限流
+type submitLimits struct {
+	keys  *rateLimiter
+	users *rateLimiter
+}
+
+// admit 检查本次提交的 n 条内容是否超出限流，超出时返回 429 并返回 false
+// n 超过令牌桶容量 (突发条数) 时返回 413，请求永远无法通过，不应重试
+// 未携带 API Key 的请求按客户端 IP 限流；user_id 按应用区分
+func (s *submitLimits) admit(c *gin.Context, userID string, n int) bool {
+	if !s.keys.fits(n) || (userID != "" && !s.users.fits(n)) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "单次提交的内容条数超过限流允许的突发条数"})
+		return false
+	}
+	caller := c.GetString(apiKeyHashKey)
+	if caller == "" {
+		caller = "ip:" + c.ClientIP()
+	}
+	if ok, wait := s.keys.allow(caller, n); !ok {
+		tooManyRequests(c, "请求过于频繁，请稍后重试", wait)
+		return false
+	}
+	if userID != "" {
+		if ok, wait := s.users.allow(c.GetString(appIDKey)+"/"+userID, n); !ok {
+			tooManyRequests(c, "该用户提交过于频繁，请稍后重试", wait)
+			return false
+		}
+	}
+	return true
+}
+
+// tooManyRequests 返回 429，Retry-After 为需要等待的秒数 (向上取整，至少 1 秒)
+func tooManyRequests(c *gin.Context, msg string, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": msg, "retry_after": seconds})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a", 1); !ok {
+			t.Fatalf("request %d within burst rejected", i)
+		}
+	}
+	ok, wait := l.allow("a", 1)
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("allow after burst = %v, %v, want false and a wait of at most 500ms", ok, wait)
+	}
+	// 被拒绝的请求不消耗令牌，其他键互不影响
+	if ok, _ := l.allow("b", 3); !ok {
+		t.Fatal("independent key rejected")
+	}
+	if ok, _ := l.allow("b", 1); ok {
+		t.Fatal("key b should be exhausted")
+	}
+}
+
+func TestRateLimiterChargesFullCount(t *testing.T) {
+	l := newRateLimiter(1, 10)
+	if l.fits(11) {
+		t.Fatal("fits(11) with burst 10 = true")
+	}
+	if ok, _ := l.allow("a", 11); ok {
+		t.Fatal("allow more than burst = true")
+	}
+	if ok, _ := l.allow("a", 8); !ok {
+		t.Fatal("allow(8) rejected")
+	}
+	if ok, _ := l.allow("a", 3); ok {
+		t.Fatal("allow(3) after 8 of 10 tokens = true")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := newRateLimiter(0, 10)
+	if l != nil {
+		t.Fatal("newRateLimiter(0) should disable limiting")
+	}
+	if !l.fits(1000) {
+		t.Fatal("disabled limiter should fit any count")
+	}
+	if ok, _ := l.allow("a", 1000); !ok {
+		t.Fatal("disabled limiter rejected")
+	}
+}
+
+func TestSubmitLimitsAdmit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		limits     *submitLimits
+		userID     string
+		n          int
+		wantStatus int // 0 表示放行
+	}{
+		{"放行", &submitLimits{keys: newRateLimiter(1, 5), users: newRateLimiter(1, 5)}, "u1", 5, 0},
+		{"超过 Key 的突发条数", &submitLimits{keys: newRateLimiter(1, 5)}, "", 6, http.StatusRequestEntityTooLarge},
+		{"超过用户的突发条数", &submitLimits{users: newRateLimiter(1, 2)}, "u1", 3, http.StatusRequestEntityTooLarge},
+		{"没有 user_id 时不检查用户限流", &submitLimits{users: newRateLimiter(1, 2)}, "", 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/submit", nil)
+			ok := tt.limits.admit(c, tt.userID, tt.n)
+			if tt.wantStatus == 0 {
+				if !ok {
+					t.Fatalf("admit rejected with %d", w.Code)
+				}
+				return
+			}
+			if ok || w.Code != tt.wantStatus {
+				t.Fatalf("admit = %v, status %d, want %d", ok, w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSubmitLimitsRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limits := &submitLimits{keys: newRateLimiter(0.5, 1)}
+	newContext := func() (*gin.Context, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/submit", nil)
+		c.Set(apiKeyHashKey, "hash")
+		return c, w
+	}
+	c, _ := newContext()
+	if !limits.admit(c, "", 1) {
+		t.Fatal("first request rejected")
+	}
+	c, w := newContext()
+	if limits.admit(c, "", 1) {
+		t.Fatal("second request admitted")
+	}
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("status %d, Retry-After %q, want 429 and 2", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestQuotaEffectiveLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultLimit int
+		appQuota     int
+		want         int64
+	}{
+		{"使用默认配额", 100, 0, 100},
+		{"应用单独设置", 100, 5, 5},
+		{"应用不限", 100, -1, 0},
+		{"默认不限", 0, 0, 0},
+		{"默认不限时应用单独设置", 0, 7, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQuotaTracker(nil, tt.defaultLimit)
+			if got := q.effectiveLimit(common.Application{DailyLLMQuota: tt.appQuota}); got != tt.want {
+				t.Fatalf("effectiveLimit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaReserveWithoutApp(t *testing.T) {
+	q := newQuotaTracker(nil, 1)
+	if ok, _ := q.reserve(""); !ok {
+		t.Fatal("requests without an app should not be limited")
+	}
+}
+
+func TestUntilTomorrow(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	tests := []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{time.Date(2026, 10, 18, 23, 0, 0, 0, loc), time.Hour},
+		{time.Date(2026, 10, 18, 0, 0, 0, 0, loc), 24 * time.Hour},
+		{time.Date(2026, 12, 31, 23, 59, 30, 0, loc), 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := untilTomorrow(tt.now); got != tt.want {
+			t.Errorf("untilTomorrow(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
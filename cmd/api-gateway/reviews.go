@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"github.com/safeflow-project/safeflow/internal/vectordb"
+	"gorm.io/gorm"
+)
+
+// 案例标签
+const (
+	labelSafe   = "safe"
+	labelUnsafe = "unsafe"
+)
+
+// autoPromoteRule 自动沉淀规则: 复核人确认拦截且模型置信度不低于阈值的审核记录直接沉淀为案例
+type autoPromoteRule struct {
+	enabled       bool
+	minConfidence float64
+}
+
+// match 判断复核后的审计记录是否满足自动沉淀条件
+func (r autoPromoteRule) match(audit *common.AuditLog) bool {
+	return r.enabled && audit.Action == "block" && audit.ReviewLabel == labelUnsafe && audit.Confidence >= r.minConfidence
+}
+
+// registerReviewRoutes 注册审核结果复核与案例沉淀 API
+// 复核结论 (最终标签与类别) 连同原始内容写入案例库并同步到 Milvus，案例通过 source_request_id 溯源到审核请求
+func registerReviewRoutes(admin *gin.RouterGroup, db *gorm.DB, nc *nats.Conn, store *vectordb.CaseStore, rule autoPromoteRule) {
+	// 提交复核结论，满足自动沉淀规则时同时沉淀为案例
+	admin.POST("/audits/:id/review", func(c *gin.Context) {
+		var audit common.AuditLog
+		if err := auditScope(db, c).First(&audit, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Audit log not found"})
+			return
+		}
+		var body struct {
+			Label    string `json:"label" binding:"required,oneof=safe unsafe"`
+			Category string `json:"category"`
+			Promote  bool   `json:"promote"` // 是否立即沉淀为案例
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		recordBefore(c, audit)
+		// 复核人为当前登录的管理员
+		now := time.Now()
+		audit.ReviewLabel, audit.Reviewer, audit.ReviewedAt = body.Label, currentAdmin(c).Username, &now
+		audit.ReviewCategory = body.Category
+		if audit.ReviewCategory == "" && body.Label == labelUnsafe {
+			audit.ReviewCategory = audit.Category
+		}
+		if err := db.Model(&audit).Select("review_label", "review_category", "reviewer", "reviewed_at").Updates(&audit).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, audit)
+
+		// 已沉淀的记录复核结论变化时同步更新案例
+		if body.Promote || audit.CaseID != 0 || rule.match(&audit) {
+			kase, err := promoteAudit(c.Request.Context(), db, nc, store, &audit)
+			if err != nil {
+				c.JSON(promoteErrorStatus(err), gin.H{"error": err.Error(), "audit": audit})
+				return
+			}
+			recordAfter(c, gin.H{"audit": audit, "case": kase})
+			c.JSON(http.StatusOK, gin.H{"audit": audit, "case": kase})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"audit": audit})
+	})
+
+	// 将审计记录沉淀为案例，未复核的记录按审核动作推断标签 (block -> unsafe, allow -> safe)
+	admin.POST("/audits/:id/promote", func(c *gin.Context) {
+		var audit common.AuditLog
+		if err := auditScope(db, c).First(&audit, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Audit log not found"})
+			return
+		}
+		recordBefore(c, audit)
+		kase, err := promoteAudit(c.Request.Context(), db, nc, store, &audit)
+		if err != nil {
+			c.JSON(promoteErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		recordAfter(c, gin.H{"audit": audit, "case": kase})
+		c.JSON(http.StatusOK, gin.H{"audit": audit, "case": kase})
+	})
+}
+
+// errNotPromotable 审计记录缺少内容或无法确定最终标签
+var errNotPromotable = errors.New("审计记录无法沉淀为案例")
+
+func promoteErrorStatus(err error) int {
+	if errors.Is(err, errNotPromotable) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// finalLabel 返回审计记录的最终标签和类别: 复核结论优先，否则按审核动作推断
+func finalLabel(audit *common.AuditLog) (label, category string, ok bool) {
+	if audit.ReviewLabel != "" {
+		return audit.ReviewLabel, audit.ReviewCategory, true
+	}
+	switch audit.Action {
+	case "block":
+		return labelUnsafe, audit.Category, true
+	case "allow":
+		return labelSafe, "", true
+	}
+	return "", "", false
+}
+
+// promoteAudit 以审计记录的内容和最终标签创建 (或更新已沉淀的) 案例，并同步到 Milvus
+// 案例归属于审计记录的调用方应用，只参与该应用的审核
+func promoteAudit(ctx context.Context, db *gorm.DB, nc *nats.Conn, store *vectordb.CaseStore, audit *common.AuditLog) (*common.Case, error) {
+	label, category, ok := finalLabel(audit)
+	if !ok {
+		return nil, fmt.Errorf("%w: 审核动作为 %s，请先提交复核结论", errNotPromotable, audit.Action)
+	}
+	if audit.Content == "" {
+		return nil, fmt.Errorf("%w: 审计记录没有保存审核内容", errNotPromotable)
+	}
+	if len(audit.Content) > vectordb.MaxContentBytes {
+		return nil, fmt.Errorf("%w: %v", errNotPromotable, vectordb.ErrContentTooLong)
+	}
+
+	var kase common.Case
+	if audit.CaseID != 0 {
+		if err := db.First(&kase, audit.CaseID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if kase.ID != 0 && kase.Content == audit.Content && kase.Label == label && kase.Category == category && kase.AppID == audit.AppID && kase.VectorID != 0 {
+		return &kase, nil
+	}
+	kase.Content, kase.Label, kase.Category = audit.Content, label, category
+	kase.SourceRequestID, kase.AppID = audit.RequestID, audit.AppID
+	if err := db.Save(&kase).Error; err != nil {
+		return nil, err
+	}
+	if audit.CaseID != kase.ID {
+		audit.CaseID = kase.ID
+		if err := db.Model(audit).UpdateColumn("case_id", kase.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	syncCase(ctx, db, store, &kase)
+	publishPolicyChanged(nc, "case", "case:"+strconv.Itoa(int(kase.ID)))
+	return &kase, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+const (
+	// tenantHeader 管理 API 选择所操作应用的请求头，不携带时操作全局 (所有应用共享的) 策略
+	tenantHeader = "X-App-ID"
+	// tenantKey gin 上下文中保存管理 API 所操作应用 ID 的键
+	tenantKey = "tenant"
+)
+
+// tenantMiddleware 解析管理请求所操作的应用，应用必须已存在
+// 规则、案例、词典、提示词等策略按应用隔离: 携带 X-App-ID 时只能读写该应用的策略，不携带时读写全局策略。
+// 限定了应用的账号总是操作该应用
+func tenantMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appID := c.GetHeader(tenantHeader)
+		if user := currentAdmin(c); user != nil && user.AppID != "" {
+			if appID != "" && appID != user.AppID {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "账号只能管理应用 " + user.AppID})
+				return
+			}
+			appID = user.AppID
+		}
+		if appID != "" {
+			exists, err := appExists(db, appID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !exists {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "应用不存在: " + appID})
+				return
+			}
+		}
+		c.Set(tenantKey, appID)
+		c.Next()
+	}
+}
+
+// appExists 返回应用是否存在
+func appExists(db *gorm.DB, appID string) (bool, error) {
+	var count int64
+	err := db.Model(&common.Application{}).Where("app_id = ?", appID).Count(&count).Error
+	return count > 0, err
+}
+
+// tenantOf 返回管理请求所操作的应用 ID，空串表示全局
+func tenantOf(c *gin.Context) string {
+	return c.GetString(tenantKey)
+}
+
+// scoped 返回限定在当前应用数据范围内的查询
+func scoped(db *gorm.DB, c *gin.Context) *gorm.DB {
+	return db.Where("app_id = ?", tenantOf(c))
+}
+
+// auditScope 返回审计数据 (审计日志、执行轨迹) 的查询范围
+// 审计数据总是属于某个调用方应用: 携带 X-App-ID 时只查询该应用的记录，不携带时查询所有应用
+func auditScope(db *gorm.DB, c *gin.Context) *gorm.DB {
+	if tenantOf(c) == "" {
+		return db
+	}
+	return scoped(db, c)
+}
@@ -11,17 +11,6 @@ import (
 	"gorm.io/gorm"
 )
 
-// AuditLog 定义审计日志的数据库模型
-type AuditLog struct {
-	ID        uint      `gorm:"primaryKey"` // 自增主键
-	RequestID string    `gorm:"index"`      // 请求 ID (建立索引以加速查询)
-	UserID    string    // 用户 ID
-	Action    string    // 动作 (allow, block, review)
-	Reason    string    // 原因
-	Source    string    // 来源 (rule-engine, llm-agent)
-	CreatedAt time.Time // 创建时间
-}
-
 func main() {
 	// 加载配置和日志
 	cfg, _ := common.LoadConfig()
@@ -44,7 +33,7 @@ func main() {
 	}
 
 	// 自动迁移数据库结构 (创建表)
-	db.AutoMigrate(&AuditLog{})
+	db.AutoMigrate(&common.AuditLog{}, &common.AgentTrace{})
 
 	// 2. 连接 NATS
 	nc, _, err := common.InitNATS(cfg.NatsURL)
@@ -64,12 +53,17 @@ func main() {
 
 		// 构建日志对象
 		logEntry := common.AuditLog{
-			RequestID: event.RequestID,
-			UserID:    event.UserID,
-			Action:    event.Action,
-			Reason:    event.Reason,
-			Source:    event.Source,
-			CreatedAt: time.Now(),
+			RequestID:      event.RequestID,
+			UserID:         event.UserID,
+			Action:         event.Action,
+			Reason:         event.Reason,
+			Source:         event.Source,
+			AppID:          event.AppID,
+			CreatedAt:      time.Now(),
+			ConversationID: event.ConversationID,
+			Content:        event.Content,
+			Category:       event.Category,
+			Confidence:     event.Confidence,
 		}
 
 		// 写入数据库
@@ -84,6 +78,30 @@ func main() {
 		logger.Fatal("订阅主题失败", zap.Error(err))
 	}
 
+	// 4. 订阅 Agent 执行轨迹主题 (agent.trace)
+	_, err = nc.Subscribe(common.SubjectAgentTrace, func(msg *nats.Msg) {
+		var event common.AgentTraceEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			logger.Error("反序列化轨迹事件失败", zap.Error(err))
+			return
+		}
+
+		trace := common.AgentTrace{
+			RequestID:   event.RequestID,
+			AppID:       event.AppID,
+			Steps:       string(event.Steps),
+			TotalTokens: event.TotalTokens,
+			LatencyMs:   event.LatencyMs,
+			CreatedAt:   time.Now(),
+		}
+		if err := db.Create(&trace).Error; err != nil {
+			logger.Error("保存执行轨迹失败", zap.Error(err))
+		}
+	})
+	if err != nil {
+		logger.Fatal("订阅轨迹主题失败", zap.Error(err))
+	}
+
 	logger.Info("审计服务已启动")
 	// 阻塞主进程
 	select {}
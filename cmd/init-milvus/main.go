@@ -2,29 +2,36 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"log"
-	"os"
+	"strings"
+	"time"
 
-	ark_embed "github.com/cloudwego/eino-ext/components/embedding/ark"
-	"github.com/milvus-io/milvus-sdk-go/v2/client"
-	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	"github.com/safeflow-project/safeflow/internal/common"
+	"github.com/safeflow-project/safeflow/internal/vectordb"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 )
 
-// Case 定义案例数据结构
-type Case struct {
-	Text     string `json:"text"`     // 文本内容
-	Label    string `json:"label"`    // 标签 (safe/unsafe)
-	Category string `json:"category"` // 类别
+// pending 是一条需要写入的案例及其写入后要删除的旧向量
+type pending struct {
+	record   vectordb.Record
+	replaces []int64
 }
 
-const (
-	CollectionName = "sensitive_cases" // 集合名称
-	Dim            = 4096              // 向量维度 (Ark embedding 为 4096)
-)
+// report 导入结果统计
+type report struct {
+	added, updated, skipped, failed int
+	tooLong                         int // 内容超过集合字段长度，无法写入
+}
 
 func main() {
+	sources := flag.String("source", "assets/examples/cases.json", "数据源，逗号分隔: mysql 或 .json/.jsonl/.csv 文件")
+	batchSize := flag.Int("batch", 16, "每批 Embedding 的案例数")
+	retries := flag.Int("retries", 3, "Embedding 或写入失败时的重试次数")
+	recreate := flag.Bool("recreate", false, "删除并重建集合后全量导入")
+	flag.Parse()
+
 	ctx := context.Background()
 
 	// 0. 加载配置
@@ -33,141 +40,174 @@ func main() {
 		log.Fatal("加载配置失败:", err)
 	}
 
-	// 1. 连接 Milvus 向量数据库
-	c, err := client.NewClient(ctx, client.Config{
-		Address: cfg.MilvusAddr,
-	})
+	// 1. 连接 Milvus 并初始化 Embedder (向量维度取自 Embedding 模型)
+	store, err := vectordb.NewCaseStore(ctx, cfg)
 	if err != nil {
-		log.Fatal("连接 milvus 失败:", err)
+		log.Fatal(err)
 	}
-	defer c.Close()
+	defer store.Close(ctx)
 
-	// 2. 初始化 Embedder (用于将文本转换为向量)
-	emb, err := ark_embed.NewEmbedder(ctx, &ark_embed.EmbeddingConfig{
-		APIKey: cfg.ArkAPIKey,
-		Model:  cfg.ArkEmbeddingModel,
-	})
-	if err != nil {
-		log.Fatal("初始化 embedder 失败:", err)
+	// 2. 创建集合 (已存在时校验定义并保留数据，增量导入)
+	if err := store.EnsureCollection(ctx, *recreate); err != nil {
+		log.Fatal(err)
 	}
 
-	// 3. 创建集合 (Collection)
-	// 检查集合是否存在，如果存在则删除 (重新初始化)
-	has, err := c.HasCollection(ctx, CollectionName)
-	if err != nil {
-		log.Fatal("检查集合失败:", err)
-	}
-	if has {
-		err = c.DropCollection(ctx, CollectionName)
+	// 3. 读取数据源
+	var records []vectordb.Record
+	for _, source := range strings.Split(*sources, ",") {
+		if source = strings.TrimSpace(source); source == "" {
+			continue
+		}
+		loaded, err := loadSource(cfg, source)
 		if err != nil {
-			log.Fatal("删除集合失败:", err)
+			log.Fatalf("读取数据源 %s 失败: %v", source, err)
 		}
+		log.Printf("数据源 %s: %d 条案例", source, len(loaded))
+		records = append(records, loaded...)
 	}
 
-	// 定义集合 Schema
-	schema := &entity.Schema{
-		CollectionName: CollectionName,
-		Description:    "SafeFlow 敏感案例库",
-		Fields: []*entity.Field{
-			{
-				Name:       "id",
-				DataType:   entity.FieldTypeInt64,
-				PrimaryKey: true,
-				AutoID:     true, // 自动生成 ID
-			},
-			{
-				Name:     "vector",
-				DataType: entity.FieldTypeFloatVector,
-				TypeParams: map[string]string{
-					"dim": "4096", // 必须与 Embedding 模型维度一致
-				},
-			},
-			{
-				Name:     "content",
-				DataType: entity.FieldTypeVarChar,
-				TypeParams: map[string]string{
-					"max_length": "2048",
-				},
-			},
-			{
-				Name:     "label",
-				DataType: entity.FieldTypeVarChar,
-				TypeParams: map[string]string{
-					"max_length": "64",
-				},
-			},
-		},
-	}
-
-	err = c.CreateCollection(ctx, schema, entity.DefaultShardNumber)
+	// 4. 与集合现有数据比对，得到需要写入的案例
+	existing, err := store.Entries(ctx, "case_id >= 0")
 	if err != nil {
-		log.Fatal("创建集合失败:", err)
+		log.Fatal("读取集合失败:", err)
 	}
+	todo, rep, caseVectors := plan(records, existing)
+	log.Printf("待写入 %d 条, 跳过 %d 条 (集合中已存在相同内容), 内容超长 %d 条", len(todo), rep.skipped, rep.tooLong)
 
-	// 4. 创建索引 (Index)
-	// 使用 IVF_FLAT 索引，L2 (欧氏距离)
-	idx, err := entity.NewIndexIvfFlat(entity.L2, Dim)
-	if err != nil {
-		log.Fatal("创建索引实体失败:", err)
+	// 5. 分批 Embedding 并写入
+	if *batchSize <= 0 {
+		*batchSize = 16
 	}
-	err = c.CreateIndex(ctx, CollectionName, "vector", idx, false)
-	if err != nil {
-		log.Fatal("创建索引失败:", err)
+	for start := 0; start < len(todo); start += *batchSize {
+		end := min(start+*batchSize, len(todo))
+		batch := todo[start:end]
+		ids, err := writeBatch(ctx, store, batch, *retries)
+		if err != nil {
+			log.Printf("第 %d~%d 条写入失败: %v", start+1, end, err)
+			rep.failed += len(batch)
+			continue
+		}
+		for i, p := range batch {
+			if len(p.replaces) > 0 {
+				if err := store.Delete(ctx, p.replaces...); err != nil {
+					log.Printf("删除旧向量失败 %v: %v", p.replaces, err)
+				}
+				rep.updated++
+			} else {
+				rep.added++
+			}
+			if p.record.CaseID > 0 {
+				caseVectors[p.record.CaseID] = ids[i]
+			}
+		}
+		log.Printf("进度: %d/%d", end, len(todo))
 	}
 
-	// 5. 加载数据 (cases.json)
-	file, err := os.ReadFile("assets/examples/cases.json")
-	if err != nil {
-		log.Fatal("读取 cases.json 失败:", err)
-	}
-	var cases []Case
-	if err := json.Unmarshal(file, &cases); err != nil {
-		log.Fatal("解析 cases 失败:", err)
+	// 6. 回写 MySQL 案例的向量 ID
+	if len(caseVectors) > 0 {
+		if err := updateVectorIDs(cfg, caseVectors); err != nil {
+			log.Printf("回写案例向量 ID 失败: %v", err)
+		}
 	}
 
-	// 6. 批量 Embedding 并插入数据
-	var vectors [][]float32
-	var contents []string
-	var labels []string
-	var texts []string
+	log.Printf("导入完成: 新增 %d 条, 更新 %d 条, 跳过 %d 条, 内容超长 %d 条, 失败 %d 条", rep.added, rep.updated, rep.skipped, rep.tooLong, rep.failed)
+}
 
-	for _, item := range cases {
-		texts = append(texts, item.Text)
-		contents = append(contents, item.Text)
-		labels = append(labels, item.Label)
+// plan 按内容哈希比对输入与集合现有数据
+// 相同内容 (且属于同一 MySQL 案例) 的条目已存在且标签、类别、所属应用一致时跳过；这些属性变化时写入新向量并删除旧向量。
+// 来自 MySQL 的案例内容变化后，该案例的旧向量也会被替换。返回的 map 记录跳过的 MySQL 案例已有的向量 ID
+func plan(records []vectordb.Record, existing []vectordb.Entry) ([]pending, report, map[int64]int64) {
+	type key struct {
+		hash   string
+		caseID int64
+	}
+	index := make(map[key]vectordb.Entry, len(existing))
+	byCase := make(map[int64][]vectordb.Entry)
+	for _, e := range existing {
+		index[key{e.Hash, e.CaseID}] = e
+		if e.CaseID > 0 {
+			byCase[e.CaseID] = append(byCase[e.CaseID], e)
+		}
 	}
 
-	// 调用 API 获取 Embedding
-	embeddings, err := emb.EmbedStrings(ctx, texts)
-	if err != nil {
-		log.Fatal("embedding 失败:", err)
-	}
-	log.Printf("Embedding 成功: 输入 %d 条, 返回 %d 条, 维度 %d", len(texts), len(embeddings), len(embeddings[0]))
+	var rep report
+	var todo []pending
+	caseVectors := make(map[int64]int64)
+	seen := make(map[key]bool)
+	for _, r := range records {
+		if len(r.Content) > vectordb.MaxContentBytes {
+			log.Printf("跳过内容超过 %d 字节的案例 (case=%d, %d 字节)", vectordb.MaxContentBytes, r.CaseID, len(r.Content))
+			rep.tooLong++
+			continue
+		}
+		k := key{vectordb.ContentHash(r.Content), r.CaseID}
+		if seen[k] {
+			rep.skipped++
+			continue
+		}
+		seen[k] = true
+
+		e, ok := index[k]
+		if ok && e.Label == r.Label && e.Category == r.Category && e.AppID == r.AppID {
+			rep.skipped++
+			if r.CaseID > 0 {
+				caseVectors[r.CaseID] = e.VectorID
+			}
+			continue
+		}
 
-	// 转换 [][]float64 为 [][]float32 (Milvus SDK 要求 float32)
-	for _, v64 := range embeddings {
-		v32 := make([]float32, len(v64))
-		for i, f := range v64 {
-			v32[i] = float32(f)
+		p := pending{record: r}
+		if ok {
+			p.replaces = append(p.replaces, e.VectorID)
 		}
-		vectors = append(vectors, v32)
+		for _, old := range byCase[r.CaseID] {
+			if old.VectorID != e.VectorID {
+				p.replaces = append(p.replaces, old.VectorID)
+			}
+		}
+		todo = append(todo, p)
 	}
+	return todo, rep, caseVectors
+}
 
-	// 插入数据
-	_, err = c.Insert(ctx, CollectionName, "",
-		entity.NewColumnFloatVector("vector", Dim, vectors),
-		entity.NewColumnVarChar("content", contents),
-		entity.NewColumnVarChar("label", labels),
-	)
-	if err != nil {
-		log.Fatal("插入数据失败:", err)
+// writeBatch 计算一批案例的向量并写入集合，失败时按指数退避重试
+func writeBatch(ctx context.Context, store *vectordb.CaseStore, batch []pending, retries int) ([]int64, error) {
+	records := make([]vectordb.Record, len(batch))
+	texts := make([]string, len(batch))
+	for i, p := range batch {
+		records[i], texts[i] = p.record, p.record.Content
+	}
+
+	var err error
+	backoff := time.Second
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("重试 (%d/%d): %v", attempt, retries, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var vectors [][]float32
+		if vectors, err = store.Embed(ctx, texts); err != nil {
+			continue
+		}
+		var ids []int64
+		if ids, err = store.Insert(ctx, records, vectors); err == nil {
+			return ids, nil
+		}
 	}
+	return nil, err
+}
 
-	// 加载集合到内存 (以便进行搜索)
-	err = c.LoadCollection(ctx, CollectionName, false)
+// updateVectorIDs 把写入或已存在的向量 ID 回写到 MySQL 案例
+func updateVectorIDs(cfg *common.Config, caseVectors map[int64]int64) error {
+	db, err := gorm.Open(mysql.Open(cfg.MySQLDSN), &gorm.Config{})
 	if err != nil {
-		log.Fatal("加载集合失败:", err)
+		return err
 	}
-
-	log.Println("成功初始化 Milvus，共导入", len(cases), "条案例。")
+	for caseID, vectorID := range caseVectors {
+		if err := db.Model(&common.Case{}).Where("id = ?", caseID).UpdateColumn("vector_id", vectorID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
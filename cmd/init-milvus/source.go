@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/safeflow-project/safeflow/internal/common"
+	"github.com/safeflow-project/safeflow/internal/vectordb"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Case 定义案例文件中的一条案例
+type Case struct {
+	Text     string `json:"text"`     // 文本内容
+	Content  string `json:"content"`  // 文本内容 (text 的别名)
+	Label    string `json:"label"`    // 标签 (safe/unsafe)
+	Category string `json:"category"` // 类别
+}
+
+func (c Case) record() vectordb.Record {
+	text := c.Text
+	if text == "" {
+		text = c.Content
+	}
+	return vectordb.Record{Content: text, Label: c.Label, Category: c.Category}
+}
+
+// loadSource 读取一个数据源: "mysql" 表示 MySQL 案例库，其他按扩展名读取 JSON 数组、JSONL 或 CSV 文件
+func loadSource(cfg *common.Config, source string) ([]vectordb.Record, error) {
+	if source == "mysql" {
+		return loadMySQL(cfg)
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cases []Case
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&cases)
+	case ".jsonl":
+		cases, err = readJSONL(f)
+	case ".csv":
+		cases, err = readCSV(f)
+	default:
+		return nil, fmt.Errorf("不支持的文件格式: %s", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]vectordb.Record, 0, len(cases))
+	for _, c := range cases {
+		if r := c.record(); strings.TrimSpace(r.Content) != "" {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// loadMySQL 读取 MySQL 案例库中未删除的案例，写入时携带案例 ID
+func loadMySQL(cfg *common.Config) ([]vectordb.Record, error) {
+	db, err := gorm.Open(mysql.Open(cfg.MySQLDSN), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("连接 MySQL 失败: %w", err)
+	}
+	var cases []common.Case
+	if err := db.Find(&cases).Error; err != nil {
+		return nil, err
+	}
+	records := make([]vectordb.Record, 0, len(cases))
+	for _, c := range cases {
+		records = append(records, vectordb.Record{CaseID: int64(c.ID), AppID: c.AppID, Content: c.Content, Label: c.Label, Category: c.Category})
+	}
+	return records, nil
+}
+
+// readJSONL 每行一个 JSON 对象，空行忽略
+func readJSONL(r io.Reader) ([]Case, error) {
+	var cases []Case
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
+
+// readCSV 首行为表头，需包含 text (或 content) 列，label、category 列可选
+func readCSV(r io.Reader) ([]Case, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	textCol, ok := columns["text"]
+	if !ok {
+		if textCol, ok = columns["content"]; !ok {
+			return nil, fmt.Errorf("CSV 缺少 text 或 content 列")
+		}
+	}
+	get := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	cases := make([]Case, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		if textCol >= len(row) {
+			continue
+		}
+		cases = append(cases, Case{Text: row[textCol], Label: get(row, "label"), Category: get(row, "category")})
+	}
+	return cases, nil
+}
@@ -3,68 +3,229 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"strings"
+	"log"
+	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/safeflow-project/safeflow/internal/agent"
 	"github.com/safeflow-project/safeflow/internal/common"
 	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+	"gorm.io/gorm"
 )
 
 // LLMAgentServiceImpl 实现 LLMAgentService 接口
 type LLMAgentServiceImpl struct {
-	agent *agent.EinoAgent
+	agent    *agent.EinoAgent
+	cache    *agent.VerdictCache  // 按归一化内容缓存结论，避免重复内容反复调用大模型
+	semantic *agent.SemanticCache // 按语义相似度复用结论 (可能为 nil)
+	nc       *nats.Conn           // 用于发布执行轨迹 (可能为 nil)
 }
 
 // NewLLMAgentServiceImpl 创建新的服务实现实例
-func NewLLMAgentServiceImpl(ctx context.Context, cfg *common.Config) *LLMAgentServiceImpl {
+// nc 不为空且开启 TRACE_PUBLISH 时，每次 Agent 执行的轨迹会发布到 NATS
+func NewLLMAgentServiceImpl(ctx context.Context, cfg *common.Config, db *gorm.DB, nc *nats.Conn) *LLMAgentServiceImpl {
 	// 初始化 Eino Agent (包含图编排、模型加载等)
-	a, err := agent.NewEinoAgent(ctx, cfg)
+	a, err := agent.NewEinoAgent(ctx, cfg, db)
 	if err != nil {
 		// 在生产环境中，应该优雅地处理错误
 		// 这里如果配置缺失或初始化失败，我们选择快速失败 (Panic)
 		panic(err)
 	}
-	return &LLMAgentServiceImpl{agent: a}
+	impl := &LLMAgentServiceImpl{
+		agent:    a,
+		cache:    agent.NewVerdictCache(cfg.VerdictCacheSize, cfg.VerdictCacheTTL),
+		semantic: agent.NewSemanticCache(ctx, cfg, a.Embedder()),
+	}
+	if cfg.TracePublish {
+		impl.nc = nc
+	}
+	return impl
 }
 
 // Scan 处理内容扫描请求
 func (s *LLMAgentServiceImpl) Scan(ctx context.Context, req *safeflow.ScanRequest) (resp *safeflow.ScanResponse, err error) {
+	start := time.Now()
 	// 初始化默认响应 (Review - 需要人工复核)
 	resp = &safeflow.ScanResponse{
 		RequestId: req.RequestId,
 		Source:    "llm-agent",
 		Action:    "review",
 	}
+	defer enforceGuardAction(req.Mode, resp)
+
+	agentReq := &agent.Request{
+		RequestID: req.RequestId,
+		AppID:     req.AppId,
+		Content:   req.Content,
+		Scene:     req.Scene,
+		Language:  req.Language,
+		Mode:      req.Mode,
+	}
+	if len(req.Conversation) > 0 {
+		agentReq.TargetIndex = int(req.TargetIndex)
+		for _, msg := range req.Conversation {
+			agentReq.Conversation = append(agentReq.Conversation, agent.Turn{Role: msg.Role, Content: msg.Content, UserID: msg.UserId})
+		}
+	}
+
+	// 先查结论缓存 (键包含提示词与模型版本；带对话上下文时以整段对话为键)
+	input := agentReq.Input()
+	policyVersion := s.agent.PolicyVersion(agentReq)
+	cacheKey := agent.CacheKey(input, req.Scene, req.Language, policyVersion)
+	if verdict, ok := s.cache.Get(cacheKey); ok {
+		// 缓存键基于归一化内容，违规片段需在当前内容中重新定位
+		hit := *verdict
+		hit.Spans = agent.LocateSpans(req.Content, verdict.Spans)
+		resp.Source = "cache"
+		fillResponse(resp, &hit)
+		return resp, nil
+	}
+
+	// 再查语义缓存 (措辞不同但语义几乎相同的内容)
+	// 输出护栏的改写文本只适用于原对话，不跨对话复用
+	semantic := req.Mode != common.ScanModeOutputGuard
+	scope := agent.CacheScope(req.Scene, req.Language, policyVersion)
+	var (
+		verdict *agent.Verdict
+		vector  []float32
+		ok      bool
+	)
+	if semantic {
+		verdict, vector, ok = s.semantic.Lookup(ctx, input, scope)
+	}
+	if ok {
+		// 语义缓存命中的原文措辞不同，违规片段需在当前内容中重新定位
+		hit := *verdict
+		hit.Spans = agent.LocateSpans(req.Content, verdict.Spans)
+		s.cache.Put(cacheKey, &hit)
+		resp.Source = "semantic-cache"
+		fillResponse(resp, &hit)
+		return resp, nil
+	}
 
 	// 运行 Eino Agent
-	resultStr, err := s.agent.Run(ctx, req.Content)
+	verdict, err = s.agent.Run(ctx, agentReq)
 	if err != nil {
 		resp.Reason = "Agent 运行错误: " + err.Error()
 		return resp, nil
 	}
+	s.publishTrace(req.RequestId, req.AppId, verdict.Trace, time.Since(start))
+	if req.IncludeTrace {
+		resp.Trace = toTraceSteps(verdict.Trace)
+	}
+	if !verdict.Degraded {
+		// 轨迹只属于本次执行，不进入缓存
+		cached := *verdict
+		cached.Trace = nil
+		s.cache.Put(cacheKey, &cached)
+		if semantic {
+			s.semantic.Store(ctx, vector, scope, &cached)
+		}
+	}
+
+	fillResponse(resp, verdict)
+	return resp, nil
+}
+
+// enforceGuardAction 输出护栏只有 allow、block、rewrite 三种结论
+// Agent 运行错误、模型输出无法解析等产生的其他结论 (如 review) 无法确认回复安全，一律按拦截处理
+func enforceGuardAction(mode string, resp *safeflow.ScanResponse) {
+	if mode != common.ScanModeOutputGuard {
+		return
+	}
+	switch resp.Action {
+	case "allow", "block", "rewrite":
+		return
+	}
+	resp.Action = "block"
+	resp.Rewrite = ""
+	resp.Reason += " (输出护栏无法确认回复安全，按拦截处理)"
+}
 
-	// 解析 Agent 返回的 JSON 结果
-	// 清理可能存在的 Markdown 代码块标记
-	cleanedResult := cleanJSON(resultStr)
-	var decision struct {
-		Action string `json:"action"`
-		Reason string `json:"reason"`
+// publishTrace 将执行轨迹发布到 NATS，由审计服务保存
+// elapsed 为请求的实际耗时 (并行的步骤耗时会重叠，不能累加步骤耗时)
+func (s *LLMAgentServiceImpl) publishTrace(requestID, appID string, steps []agent.TraceStep, elapsed time.Duration) {
+	if s.nc == nil || len(steps) == 0 {
+		return
 	}
-	if err := json.Unmarshal([]byte(cleanedResult), &decision); err != nil {
-		resp.Reason = "解析结果失败。原始输出: " + resultStr
-	} else {
-		resp.Action = decision.Action
-		resp.Reason = decision.Reason
+	data, err := json.Marshal(steps)
+	if err != nil {
+		log.Printf("序列化执行轨迹失败: %v", err)
+		return
 	}
+	event := common.AgentTraceEvent{RequestID: requestID, AppID: appID, Steps: data, LatencyMs: elapsed.Milliseconds(), Timestamp: time.Now()}
+	for _, step := range steps {
+		event.TotalTokens += step.TotalTokens
+	}
+	payload, _ := json.Marshal(event)
+	if err := s.nc.Publish(common.SubjectAgentTrace, payload); err != nil {
+		log.Printf("发布执行轨迹失败: %v", err)
+	}
+}
 
+// toTraceSteps 将执行轨迹转换为 RPC 响应结构
+func toTraceSteps(steps []agent.TraceStep) []*safeflow.TraceStep {
+	out := make([]*safeflow.TraceStep, 0, len(steps))
+	for _, st := range steps {
+		out = append(out, &safeflow.TraceStep{
+			Type:             st.Type,
+			Name:             st.Name,
+			Input:            st.Input,
+			Output:           st.Output,
+			Error:            st.Error,
+			PromptTokens:     int32(st.PromptTokens),
+			CompletionTokens: int32(st.CompletionTokens),
+			TotalTokens:      int32(st.TotalTokens),
+			LatencyMs:        st.LatencyMs,
+		})
+	}
+	return out
+}
+
+// Stats 返回 Agent 运行指标
+func (s *LLMAgentServiceImpl) Stats(ctx context.Context, req *safeflow.StatsRequest) (resp *safeflow.StatsResponse, err error) {
+	cacheStats := s.cache.Stats()
+	semanticStats := s.semantic.Stats()
+	resp = &safeflow.StatsResponse{
+		Metrics: map[string]float64{
+			"cache.size":              float64(cacheStats.Size),
+			"cache.hits":              float64(cacheStats.Hits),
+			"cache.misses":            float64(cacheStats.Misses),
+			"cache.evictions":         float64(cacheStats.Evictions),
+			"cache.hit_rate":          cacheStats.HitRate(),
+			"semantic_cache.hits":     float64(semanticStats.Hits),
+			"semantic_cache.misses":   float64(semanticStats.Misses),
+			"semantic_cache.hit_rate": semanticStats.HitRate(),
+		},
+	}
+	for k, v := range s.agent.Stats() {
+		resp.Metrics[k] = v
+	}
 	return resp, nil
 }
 
-// cleanJSON 清理 JSON 字符串中的 Markdown 标记
-func cleanJSON(s string) string {
-	s = strings.TrimSpace(s)
-	s = strings.TrimPrefix(s, "```json")
-	s = strings.TrimPrefix(s, "```")
-	s = strings.TrimSuffix(s, "```")
-	return strings.TrimSpace(s)
+// onPolicyChanged 策略变更时刷新提示词并清空结论缓存
+func (s *LLMAgentServiceImpl) onPolicyChanged(event *common.PolicyChangedEvent) {
+	s.agent.ReloadPolicy()
+	s.cache.Purge()
+	s.semantic.Purge(context.Background())
+}
+
+// fillResponse 将 Agent 结论写入响应
+func fillResponse(resp *safeflow.ScanResponse, verdict *agent.Verdict) {
+	resp.Action = verdict.Action
+	resp.Reason = verdict.Reason
+	resp.PromptVersion = verdict.PromptVersion
+	resp.Category = verdict.Category
+	resp.Confidence = verdict.Confidence
+	resp.Tier = verdict.Tier
+	resp.Rewrite = verdict.Rewrite
+	resp.Spans = nil
+	for _, sp := range verdict.Spans {
+		resp.Spans = append(resp.Spans, &safeflow.Span{Start: int32(sp.Start), End: int32(sp.End), Text: sp.Text, Label: sp.Label})
+	}
+	resp.Members = nil
+	for _, m := range verdict.Members {
+		resp.Members = append(resp.Members, &safeflow.MemberVerdict{Name: m.Name, Action: m.Action, Reason: m.Reason, Error: m.Error})
+	}
 }
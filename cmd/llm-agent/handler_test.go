@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/safeflow-project/safeflow/internal/agent"
+	"github.com/safeflow-project/safeflow/internal/common"
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+)
+
+func TestEnforceGuardAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		verdict *agent.Verdict // 为空表示 Agent 运行错误 (保留默认响应)
+		want    string
+	}{
+		{"Agent 运行错误", common.ScanModeOutputGuard, nil, "block"},
+		{"模型输出无法解析", common.ScanModeOutputGuard, &agent.Verdict{Action: "review", Reason: "解析结果失败", Degraded: true}, "block"},
+		{"未知结论", common.ScanModeOutputGuard, &agent.Verdict{Action: "escalate"}, "block"},
+		{"放行", common.ScanModeOutputGuard, &agent.Verdict{Action: "allow"}, "allow"},
+		{"拦截", common.ScanModeOutputGuard, &agent.Verdict{Action: "block"}, "block"},
+		{"改写", common.ScanModeOutputGuard, &agent.Verdict{Action: "rewrite", Rewrite: "安全回复"}, "rewrite"},
+		{"其他模式保留人工复核", "", nil, "review"},
+		{"其他模式保留解析失败的结论", "", &agent.Verdict{Action: "review", Degraded: true}, "review"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &safeflow.ScanResponse{Action: "review", Source: "llm-agent"}
+			if tt.verdict != nil {
+				fillResponse(resp, tt.verdict)
+			}
+			enforceGuardAction(tt.mode, resp)
+			if resp.Action != tt.want {
+				t.Fatalf("action = %s, want %s", resp.Action, tt.want)
+			}
+			if resp.Action == "block" && resp.Rewrite != "" {
+				t.Fatalf("拦截结论不应携带改写文本: %q", resp.Rewrite)
+			}
+		})
+	}
+}
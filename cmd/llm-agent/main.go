@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
+	"time"
 
 	"github.com/cloudwego/kitex/server"
+	"github.com/nats-io/nats.go"
 	"github.com/safeflow-project/safeflow/internal/common"
 	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow/llmagentservice"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -15,10 +21,49 @@ func main() {
 	if err != nil {
 		log.Fatalf("无法加载配置: %v", err)
 	}
+	logger, _ := common.InitLogger()
+
+	// 连接数据库 (用于加载提示词模板和敏感实体词典)
+	var db *gorm.DB
+	for i := 0; i < 30; i++ {
+		db, err = gorm.Open(mysql.Open(cfg.MySQLDSN), &gorm.Config{})
+		if err == nil {
+			break
+		}
+		logger.Warn("等待 MySQL 启动...", zap.Error(err))
+		time.Sleep(2 * time.Second)
+	}
+	if err != nil {
+		logger.Fatal("连接 MySQL 失败", zap.Error(err))
+	}
+	// 自动迁移
+	db.AutoMigrate(&common.PromptTemplate{}, &common.SensitiveEntity{})
+
 	addr, _ := net.ResolveTCPAddr("tcp", "0.0.0.0:"+cfg.LLMAgentPort)
 
+	// 连接 NATS (用于接收策略变更通知、发布执行轨迹)
+	nc, _, err := common.InitNATS(cfg.NatsURL)
+	if err != nil {
+		logger.Fatal("连接 NATS 失败", zap.Error(err))
+	}
+	defer nc.Close()
+
 	// 初始化服务实现 (包含 Eino Agent 的初始化)
-	impl := NewLLMAgentServiceImpl(context.Background(), cfg)
+	impl := NewLLMAgentServiceImpl(context.Background(), cfg, db, nc)
+
+	// 订阅策略变更通知 (用于刷新提示词并使缓存失效)
+	_, err = nc.Subscribe(common.SubjectPolicyChanged, func(msg *nats.Msg) {
+		var event common.PolicyChangedEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			logger.Error("反序列化策略变更事件失败", zap.Error(err))
+			return
+		}
+		logger.Info("收到策略变更通知", zap.String("type", event.Type), zap.String("target", event.Target))
+		impl.onPolicyChanged(&event)
+	})
+	if err != nil {
+		logger.Fatal("订阅策略变更主题失败", zap.Error(err))
+	}
 
 	// 创建 Kitex 服务端
 	svr := safeflow.NewServer(impl, server.WithServiceAddr(addr))
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +17,7 @@ import (
 // RuleEngineServiceImpl 实现 RuleEngineService 接口
 type RuleEngineServiceImpl struct {
 	db          *gorm.DB
-	rules       []common.Rule
+	sets        map[string]ruleSet // 应用 ID -> 生效规则 ("" 为只含全局规则的规则集)
 	mu          sync.RWMutex
 	lastRefresh time.Time
 }
@@ -39,11 +40,22 @@ func (s *RuleEngineServiceImpl) loadRules() {
 		log.Printf("加载规则失败: %v", err)
 		return
 	}
+	sets := buildRuleSets(rules)
 	s.mu.Lock()
-	s.rules = rules
+	s.sets = sets
 	s.lastRefresh = time.Now()
 	s.mu.Unlock()
-	log.Printf("已加载 %d 条规则", len(rules))
+	log.Printf("已加载 %d 条规则 (%d 个应用)", len(rules), len(sets)-1)
+}
+
+// ruleSet 返回应用生效的规则集，应用没有自己的规则时只使用全局规则
+func (s *RuleEngineServiceImpl) ruleSet(appID string) ruleSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if rs, ok := s.sets[appID]; ok {
+		return rs
+	}
+	return s.sets[""]
 }
 
 func (s *RuleEngineServiceImpl) refreshRulesLoop() {
@@ -56,18 +68,18 @@ func (s *RuleEngineServiceImpl) refreshRulesLoop() {
 // Scan 处理内容扫描请求
 // 使用简单的关键词匹配和正则表达式进行快速过滤
 func (s *RuleEngineServiceImpl) Scan(ctx context.Context, req *safeflow.ScanRequest) (resp *safeflow.ScanResponse, err error) {
-	log.Printf("[RuleEngine] 收到请求: ID=%s, Content=%s", req.RequestId, req.Content)
+	log.Printf("[RuleEngine] 收到请求: ID=%s, App=%s, Mode=%s, Content=%s", req.RequestId, req.AppId, req.Mode, req.Content)
 
-	// 1. 定义敏感词库和正则模式
-	// 在实际生产中，这些应该从配置中心或数据库加载，并使用 AC 自动机等高效算法
-	sensitiveWords := []string{
-		"fuck", "gambling", "terror", "bomb", "kill", "suicide",
-		"casino", "drugs", "heroin",
-		"兼职", "刷单", "加微信", "博彩", "赌博", "炸弹", "自杀", "毒品", "海洛因",
-		"高薪", "日入", "不限经验",
+	// 提示词注入检测模式使用专门的启发式规则包
+	if req.Mode == common.ScanModePromptInjection {
+		return scanInjection(req), nil
+	}
+	// 输出护栏模式下只审核目标消息 (对话上下文由 LLM Agent 处理)
+	if req.Content == "" && len(req.Conversation) > 0 {
+		if i := int(req.TargetIndex); i >= 0 && i < len(req.Conversation) {
+			req.Content = req.Conversation[i].Content
+		}
 	}
-	emailRegex := regexp.MustCompile(`[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}`)
-	phoneRegex := regexp.MustCompile(`\b\d{11}\b`) // 简单的中国手机号匹配
 
 	// 初始化默认响应 (允许通过)
 	resp = &safeflow.ScanResponse{
@@ -76,28 +88,67 @@ func (s *RuleEngineServiceImpl) Scan(ctx context.Context, req *safeflow.ScanRequ
 		Action:    "allow",
 	}
 
+	// 1. 匹配数据库规则 (应用规则 + 全局规则)，命中 block/review 规则时直接返回；
+	// allow 规则只豁免优先级更低的数据库规则，不豁免下面的内置词库与隐私信息检查
+	rule, matched := s.ruleSet(req.AppId).match(req.Content)
+	if matched && rule.Action != "allow" {
+		resp.Action = rule.Action
+		resp.Reason = ruleReason(rule)
+		if rule.Action == "block" {
+			resp.Spans = findSpans(rule.re, req.Content, "rule:"+strconv.Itoa(int(rule.ID)))
+		}
+		resp.Category = rule.Group
+		return resp, nil
+	}
+
+	// 2. 内置兜底词库和正则模式
+	sensitiveWords := []string{
+		"fuck", "gambling", "terror", "bomb", "kill", "suicide",
+		"casino", "drugs", "heroin",
+		"兼职", "刷单", "加微信", "博彩", "赌博", "炸弹", "自杀", "毒品", "海洛因",
+		"高薪", "日入", "不限经验",
+	}
+	emailRegex := regexp.MustCompile(`[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}`)
+	phoneRegex := regexp.MustCompile(`\b\d{11}\b`) // 简单的中国手机号匹配
+
 	lowerContent := strings.ToLower(req.Content)
 
-	// 2. 检查敏感词
+	// 检查敏感词
 	for _, word := range sensitiveWords {
 		if strings.Contains(lowerContent, word) {
 			resp.Action = "block"
 			resp.Reason = "检测到敏感关键词: " + word
+			resp.Spans = keywordSpans(word, req.Content)
 			return resp, nil
 		}
 	}
 
-	// 3. 检查正则表达式 (个人隐私信息 PII)
+	// 检查正则表达式 (个人隐私信息 PII)
 	if emailRegex.MatchString(req.Content) {
 		resp.Action = "block"
 		resp.Reason = "检测到隐私信息: 电子邮箱"
+		resp.Spans = findSpans(emailRegex, req.Content, "pii:email")
 		return resp, nil
 	}
 	if phoneRegex.MatchString(req.Content) {
 		resp.Action = "block"
 		resp.Reason = "检测到隐私信息: 手机号码"
+		resp.Spans = findSpans(phoneRegex, req.Content, "pii:phone")
 		return resp, nil
 	}
 
+	// 放行时只说明命中的 allow 规则，不填写违规分类
+	if matched {
+		resp.Reason = ruleReason(rule)
+	}
 	return resp, nil
 }
+
+// ruleReason 返回命中规则的说明
+func ruleReason(rule *compiledRule) string {
+	reason := "命中规则: " + rule.Pattern
+	if rule.Description != "" {
+		reason += " (" + rule.Description + ")"
+	}
+	return reason
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+)
+
+// injectionRule 是提示词注入检测的启发式规则
+type injectionRule struct {
+	Name     string
+	Category string // instruction_override, jailbreak, system_prompt_extraction, data_exfiltration
+	Weight   int    // 权重，命中规则的权重之和达到阈值即拦截
+	Pattern  *regexp.Regexp
+}
+
+// injectionBlockScore 拦截阈值
+// 单条强特征 (权重 3) 即可拦截；弱特征需要组合出现，否则交给 LLM Agent 判断
+const injectionBlockScore = 3
+
+// injectionRules 内置的提示词注入/越狱规则包
+var injectionRules = []injectionRule{
+	// 指令覆盖
+	{"ignore_previous_en", "instruction_override", 3, regexp.MustCompile(`(?i)\b(ignore|disregard|override)\s+(all\s+|any\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|preceding)\s+(instructions?|prompts?|rules?|directions?)`)},
+	{"ignore_previous_zh", "instruction_override", 3, regexp.MustCompile(`(忽略|无视|忘记|忘掉)(你)?(之前|以上|上面|前面|先前)(的)?(所有|全部)?(指令|指示|规则|提示|设定|要求)`)},
+	{"forget_everything", "instruction_override", 2, regexp.MustCompile(`(?i)\bforget\s+(everything|all)\b`)},
+	{"new_instructions", "instruction_override", 1, regexp.MustCompile(`(?i)(new|updated)\s+instructions?\s*:|新的指令\s*[:：]`)},
+
+	// 越狱
+	{"dan", "jailbreak", 3, regexp.MustCompile(`\bDAN\b|(?i:do anything now)`)},
+	{"developer_mode", "jailbreak", 2, regexp.MustCompile(`(?i)developer\s+mode|开发者模式|上帝模式|god\s+mode`)},
+	{"jailbreak_word", "jailbreak", 2, regexp.MustCompile(`(?i)\bjailbreak|越狱`)},
+	{"no_restrictions_en", "jailbreak", 3, regexp.MustCompile(`(?i)(pretend|act|behave|roleplay)\s+(to\s+be\s+|as\s+|like\s+)?.{0,40}(no|without|free\s+of)\s+(any\s+)?(restrictions?|limits?|filters?|guidelines?|rules)`)},
+	{"no_restrictions_zh", "jailbreak", 2, regexp.MustCompile(`(不受|没有|无视|摆脱|解除)(任何)?(限制|约束|审查|道德|规则)`)},
+
+	// 系统提示词窃取
+	{"reveal_prompt_en", "system_prompt_extraction", 3, regexp.MustCompile(`(?i)(reveal|show|print|repeat|output|display|tell\s+me)\s+(me\s+)?(your|the)\s+(system\s+prompt|initial\s+instructions|hidden\s+(prompt|instructions)|original\s+prompt)`)},
+	{"reveal_prompt_zh", "system_prompt_extraction", 3, regexp.MustCompile(`(输出|告诉我|显示|重复|打印|泄露)(一下)?(你的)?(系统提示词|系统提示|系统指令|初始指令|原始指令|system\s*prompt)`)},
+	{"initial_instructions", "system_prompt_extraction", 2, regexp.MustCompile(`(?i)what\s+(are|were)\s+your\s+(initial|original|first)\s+(instructions|prompt)`)},
+
+	// 数据外泄
+	{"send_to_url", "data_exfiltration", 3, regexp.MustCompile(`(?i)(send|post|upload|exfiltrate|forward|transmit)\b.{0,80}\b(to|into)\s+(https?://|the\s+(url|endpoint|webhook))`)},
+	{"markdown_image_exfil", "data_exfiltration", 3, regexp.MustCompile(`!\[[^\]]*\]\(https?://[^)\s]*\?[^)\s]*=`)},
+	{"send_to_url_zh", "data_exfiltration", 2, regexp.MustCompile(`(发送|上传|转发|泄露|提交)(到|给|至).{0,40}(https?://|网址|链接|邮箱|服务器)`)},
+	{"secret_dump", "data_exfiltration", 2, regexp.MustCompile(`(?i)(api[_\s-]?key|password|secret|access\s+token|密钥|密码|令牌).{0,30}(send|print|output|reveal|发送|输出|告诉我|泄露)`)},
+}
+
+// scanInjection 使用启发式规则包检测提示词注入
+func scanInjection(req *safeflow.ScanRequest) *safeflow.ScanResponse {
+	resp := &safeflow.ScanResponse{
+		RequestId: req.RequestId,
+		Source:    "rule-engine",
+		Action:    "allow",
+	}
+
+	score := 0
+	var hits []string
+	var spans []*safeflow.Span
+	for _, rule := range injectionRules {
+		if matched := findSpans(rule.Pattern, req.Content, rule.Category); len(matched) > 0 {
+			score += rule.Weight
+			hits = append(hits, rule.Category+"/"+rule.Name)
+			spans = append(spans, matched...)
+		}
+	}
+	if score >= injectionBlockScore {
+		resp.Action = "block"
+		resp.Reason = "检测到提示词注入特征: " + strings.Join(hits, ", ")
+		resp.Spans = spans
+	} else if len(hits) > 0 {
+		// 弱特征不直接拦截，交由 LLM Agent 结合语义判断
+		resp.Reason = "存在可疑注入特征: " + strings.Join(hits, ", ")
+	}
+	return resp
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"sort"
+
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+// compiledRule 是编译后的一条规则，关键词规则编译为不区分大小写的正则
+type compiledRule struct {
+	common.Rule
+	re *regexp.Regexp
+}
+
+// ruleSet 是一个应用生效的规则，按优先级从高到低排列，第一条命中的规则决定结果
+type ruleSet []compiledRule
+
+// match 返回决定结果的规则: 按优先级第一条命中的规则决定结果，
+// 但应用的 allow 规则只能放行该应用自己的规则，之后命中的全局 block/review 规则仍然生效
+func (rs ruleSet) match(content string) (*compiledRule, bool) {
+	var allowed *compiledRule
+	for i := range rs {
+		r := &rs[i]
+		if allowed != nil && r.AppID != "" {
+			continue
+		}
+		if !r.re.MatchString(content) {
+			continue
+		}
+		if r.Action == "allow" {
+			if allowed == nil && r.AppID != "" {
+				allowed = r
+				continue
+			}
+			if allowed != nil {
+				return allowed, true
+			}
+		}
+		return r, true
+	}
+	return allowed, allowed != nil
+}
+
+// compileRule 编译一条规则，格式错误或类型不支持时返回 false
+func compileRule(r common.Rule) (compiledRule, bool) {
+	var pattern string
+	switch r.Type {
+	case "keyword":
+		pattern = `(?i)` + regexp.QuoteMeta(r.Pattern)
+	case "regex":
+		pattern = r.Pattern
+	default:
+		log.Printf("忽略不支持的规则类型 (id=%d, type=%s)", r.ID, r.Type)
+		return compiledRule{}, false
+	}
+	if r.Pattern == "" {
+		return compiledRule{}, false
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Printf("忽略无法编译的规则 (id=%d): %v", r.ID, err)
+		return compiledRule{}, false
+	}
+	return compiledRule{Rule: r, re: re}, true
+}
+
+// buildRuleSets 按应用编译规则: 全局规则 (app_id 为空) 对所有应用生效，应用自己的规则只作用于该应用
+// 同优先级时应用规则先于全局规则匹配。返回的 map 中 "" 对应只含全局规则的规则集
+func buildRuleSets(rules []common.Rule) map[string]ruleSet {
+	var global ruleSet
+	tenants := make(map[string]ruleSet)
+	for _, r := range rules {
+		cr, ok := compileRule(r)
+		if !ok {
+			continue
+		}
+		if r.AppID == "" {
+			global = append(global, cr)
+		} else {
+			tenants[r.AppID] = append(tenants[r.AppID], cr)
+		}
+	}
+
+	sets := map[string]ruleSet{"": sortRules(global)}
+	for appID, own := range tenants {
+		merged := make(ruleSet, 0, len(own)+len(global))
+		merged = append(append(merged, own...), global...)
+		sets[appID] = sortRules(merged)
+	}
+	return sets
+}
+
+func sortRules(rs ruleSet) ruleSet {
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].Priority > rs[j].Priority })
+	return rs
+}
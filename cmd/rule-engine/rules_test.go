@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/safeflow-project/safeflow/internal/common"
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+)
+
+func TestRuleSetMatch(t *testing.T) {
+	rules := []common.Rule{
+		{ID: 1, Type: "keyword", Pattern: "casino", Action: "block", Priority: 10},
+		{ID: 2, Type: "keyword", Pattern: "poker", Action: "review", Priority: 5},
+		{ID: 3, Type: "regex", Pattern: `.*`, Action: "allow", Priority: 100, AppID: "game"},
+		{ID: 4, Type: "keyword", Pattern: "poker", Action: "block", Priority: 50, AppID: "game"},
+		{ID: 5, Type: "keyword", Pattern: "card", Action: "allow", Priority: 20},
+		{ID: 6, Type: "keyword", Pattern: "card", Action: "block", Priority: 1},
+		{ID: 7, Type: "keyword", Pattern: "dice", Action: "review", Priority: 10, AppID: "shop"},
+		{ID: 8, Type: "keyword", Pattern: "dice", Action: "block", Priority: 10},
+		{ID: 9, Type: "regex", Pattern: `(`, Action: "block", Priority: 99},
+		{ID: 10, Type: "unknown", Pattern: "x", Action: "block", Priority: 99},
+	}
+	sets := buildRuleSets(rules)
+
+	tests := []struct {
+		name    string
+		appID   string
+		content string
+		wantID  uint // 0 表示不命中
+	}{
+		{"全局规则", "", "Visit the CASINO", 1},
+		{"无自有规则的应用使用全局规则", "other", "casino", 1},
+		{"未命中", "", "hello", 0},
+		{"应用 allow 规则不豁免全局 block", "game", "casino", 1},
+		{"应用 allow 规则豁免应用自己的规则", "game", "poker night", 2},
+		{"应用 allow 规则在全局规则都未命中时生效", "game", "hello", 3},
+		{"全局 allow 规则豁免优先级更低的全局规则", "", "card", 5},
+		{"同优先级时应用规则优先", "shop", "dice", 7},
+		{"应用规则不影响其他应用", "", "dice", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, ok := sets[tt.appID]
+			if !ok {
+				rs = sets[""]
+			}
+			rule, matched := rs.match(tt.content)
+			if tt.wantID == 0 {
+				if matched {
+					t.Fatalf("match(%q) = rule %d, want no match", tt.content, rule.ID)
+				}
+				return
+			}
+			if !matched || rule.ID != tt.wantID {
+				t.Fatalf("match(%q) = %v, %v, want rule %d", tt.content, rule, matched, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestBuildRuleSetsSkipsInvalidRules(t *testing.T) {
+	sets := buildRuleSets([]common.Rule{
+		{ID: 1, Type: "regex", Pattern: `(`, Action: "block"},
+		{ID: 2, Type: "unknown", Pattern: "x", Action: "block"},
+		{ID: 3, Type: "keyword", Pattern: "", Action: "block"},
+		{ID: 4, Type: "keyword", Pattern: "ok", Action: "block"},
+	})
+	if got := len(sets[""]); got != 1 {
+		t.Fatalf("len(global) = %d, want 1", got)
+	}
+}
+
+func TestScanRulePrecedence(t *testing.T) {
+	s := &RuleEngineServiceImpl{sets: buildRuleSets([]common.Rule{
+		{ID: 1, Type: "keyword", Pattern: "lottery", Action: "block", Priority: 10, Group: "gambling"},
+		{ID: 2, Type: "keyword", Pattern: "promo", Action: "review", Priority: 10, Group: "ads"},
+		{ID: 3, Type: "regex", Pattern: `lottery|promo|coupon`, Action: "allow", Priority: 100, Group: "whitelist", AppID: "shop"},
+		{ID: 4, Type: "keyword", Pattern: "coupon", Action: "block", Priority: 50, Group: "spam", AppID: "shop"},
+		{ID: 5, Type: "keyword", Pattern: "coupon", Action: "review", Priority: 5, Group: "ads"},
+		{ID: 6, Type: "keyword", Pattern: "hello", Action: "allow", Priority: 100, Group: "greeting"},
+		{ID: 7, Type: "keyword", Pattern: "vip", Action: "allow", Priority: 1, Group: "whitelist", AppID: "shop"},
+	})}
+
+	tests := []struct {
+		name         string
+		appID        string
+		content      string
+		wantAction   string
+		wantCategory string
+		wantRule     string // 理由中应包含的规则
+	}{
+		{"租户 allow 规则不豁免全局 block 规则", "shop", "lottery", "block", "gambling", "lottery"},
+		{"租户 allow 规则不豁免全局 review 规则", "shop", "promo", "review", "ads", "promo"},
+		{"租户 allow 规则豁免租户自己的 block 规则", "shop", "coupon", "review", "ads", "coupon"},
+		{"其他应用不受租户 allow 规则影响", "game", "lottery", "block", "gambling", "lottery"},
+		{"其他应用不受租户 block 规则影响", "game", "coupon", "review", "ads", "coupon"},
+		{"租户 allow 规则放行时分类为空", "shop", "vip member", "allow", "", "vip"},
+		{"全局 allow 规则放行时分类为空", "", "hello there", "allow", "", "hello"},
+		{"allow 规则不豁免内置敏感词", "", "hello, 加微信", "block", "", "加微信"},
+		{"allow 规则不豁免隐私信息", "", "hello a@b.com", "block", "", "电子邮箱"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.Scan(context.Background(), &safeflow.ScanRequest{RequestId: "r1", AppId: tt.appID, Content: tt.content})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Action != tt.wantAction || resp.Category != tt.wantCategory {
+				t.Fatalf("action, category = %s, %q, want %s, %q (%s)", resp.Action, resp.Category, tt.wantAction, tt.wantCategory, resp.Reason)
+			}
+			if !strings.Contains(resp.Reason, tt.wantRule) {
+				t.Fatalf("reason = %q, want %s", resp.Reason, tt.wantRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"regexp"
+	"unicode/utf8"
+
+	safeflow "github.com/safeflow-project/safeflow/kitex_gen/safeflow"
+)
+
+// maxSpans 单条规则最多返回的命中片段数
+const maxSpans = 20
+
+// findSpans 返回正则在内容中的所有命中片段，偏移为字符 (rune) 偏移，与 LLM Agent 的片段一致
+func findSpans(re *regexp.Regexp, content, label string) []*safeflow.Span {
+	var spans []*safeflow.Span
+	for _, loc := range re.FindAllStringIndex(content, maxSpans) {
+		start := utf8.RuneCountInString(content[:loc[0]])
+		spans = append(spans, &safeflow.Span{
+			Start: int32(start),
+			End:   int32(start + utf8.RuneCountInString(content[loc[0]:loc[1]])),
+			Text:  content[loc[0]:loc[1]],
+			Label: label,
+		})
+	}
+	return spans
+}
+
+// keywordSpans 返回关键词 (不区分大小写) 在内容中的命中片段
+func keywordSpans(word, content string) []*safeflow.Span {
+	return findSpans(regexp.MustCompile(`(?i)`+regexp.QuoteMeta(word)), content, "keyword:"+word)
+}
@@ -17,6 +17,8 @@ func main() {
 		port = "8080"
 	}
 	url := fmt.Sprintf("http://localhost:%s/submit", port)
+	// 网关要求提交审核时携带应用的 API Key
+	apiKey := os.Getenv("SAFEFLOW_API_KEY")
 
 	tests := []struct {
 		name    string
@@ -60,7 +62,12 @@ func main() {
 		}
 		jsonData, _ := json.Marshal(payload)
 
-		resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+		req, _ := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			fmt.Printf("❌ 请求失败: %v\n", err)
 			continue
@@ -68,6 +75,10 @@ func main() {
 		defer resp.Body.Close()
 
 		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusUnauthorized {
+			fmt.Printf("❌ 鉴权失败，请通过 SAFEFLOW_API_KEY 环境变量提供 API Key: %s\n", string(body))
+			continue
+		}
 
 		var result struct {
 			Action string `json:"action"`
@@ -1,16 +1,74 @@
 namespace go safeflow
 
+// ChatMessage 是对话中的一条消息
+struct ChatMessage {
+    1: string role    // system, user, assistant
+    2: string content
+    3: string user_id // 发送者 ID (IM 多人对话)
+}
+
 struct ScanRequest {
     1: string request_id
     2: string user_id
     3: string content
+    4: string scene    // 业务场景 (用于选择提示词模板)
+    5: string language // 内容语言 (如 zh, en)
+    6: string mode     // 审核模式: content (默认，内容安全), prompt_injection (提示词注入/越狱检测), output_guard (大模型回复审核)
+    7: list<ChatMessage> conversation // 对话上下文 (output_guard 模式，或 IM 会话的最近消息)
+    8: i32 target_index               // 待审核消息在 conversation 中的下标
+    9: bool include_trace             // 是否在响应中返回 Agent 执行轨迹
+    10: string app_id                 // 调用方应用 ID (由网关根据 API Key 确定)
+}
+
+// Span 是内容中的一个违规片段，start/end 为字符 (rune) 偏移，左闭右开
+struct Span {
+    1: i32 start
+    2: i32 end
+    3: string text
+    4: string label // 违规类别或命中的规则
+}
+
+// MemberVerdict 是集成模式下单个成员的结论
+struct MemberVerdict {
+    1: string name // 模型ID[@场景]
+    2: string action
+    3: string reason
+    4: string error // 成员运行失败时的错误信息
+}
+
+// TraceStep 是 Agent 执行过程中的一步 (一次模型调用或工具调用)
+struct TraceStep {
+    1: string type // model, tool
+    2: string name
+    3: string input
+    4: string output
+    5: string error
+    6: i32 prompt_tokens
+    7: i32 completion_tokens
+    8: i32 total_tokens
+    9: i64 latency_ms
 }
 
 struct ScanResponse {
     1: string request_id
-    2: string action // allow, block, review
+    2: string action // allow, block, review, rewrite
     3: string reason
     4: string source // rule-engine, llm-agent
+    5: string prompt_version // 生成结论所用的提示词版本
+    6: string rewrite        // action 为 rewrite 时给出的安全替换文本
+    7: list<Span> spans      // 违规片段 (用于高亮)
+    8: list<MemberVerdict> members // 集成模式下各成员的结论
+    9: string category             // 违规类别
+    10: double confidence          // 模型给出的置信度 (0~1)
+    11: string tier                // 给出结论的层级: fast (小模型初审), full (完整 Agent)
+    12: list<TraceStep> trace      // Agent 执行轨迹 (请求 include_trace 时返回)
+}
+
+struct StatsRequest {
+}
+
+struct StatsResponse {
+    1: map<string, double> metrics // 运行指标 (如缓存命中率)
 }
 
 service RuleEngineService {
@@ -19,4 +77,5 @@ service RuleEngineService {
 
 service LLMAgentService {
     ScanResponse Scan(1: ScanRequest req)
+    StatsResponse Stats(1: StatsRequest req)
 }
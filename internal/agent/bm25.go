@@ -0,0 +1,233 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+// 案例检索方式 (RETRIEVER_MODE)
+const (
+	// RetrieverModeHybrid 向量检索与 BM25 融合，向量检索不可用时退化为 BM25 (默认)
+	RetrieverModeHybrid = "hybrid"
+	// RetrieverModeVector 只使用向量检索
+	RetrieverModeVector = "vector"
+	// RetrieverModeBM25 只使用 BM25 词法检索
+	RetrieverModeBM25 = "bm25"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	// defaultRetrieveTopK 检索未指定 TopK 时返回的数量
+	defaultRetrieveTopK = 3
+)
+
+// bm25Doc 是索引中的一个案例
+type bm25Doc struct {
+	c      common.Case
+	tf     map[string]int
+	length int
+}
+
+// BM25Retriever 基于 MySQL 案例库的进程内词法检索
+// 在 Embedding 或 Milvus 不可用时作为兜底，也可与向量检索结果融合
+type BM25Retriever struct {
+	db          *gorm.DB
+	mu          sync.RWMutex
+	docs        []bm25Doc
+	postings    map[string][]int // 词 -> 包含该词的文档下标
+	avgLen      float64
+	lastRefresh time.Time
+}
+
+// NewBM25Retriever 创建词法检索器并启动定期刷新
+func NewBM25Retriever(db *gorm.DB) *BM25Retriever {
+	r := &BM25Retriever{db: db, postings: make(map[string][]int)}
+	r.load()
+	go r.refreshLoop()
+	return r
+}
+
+func (r *BM25Retriever) load() {
+	var cases []common.Case
+	if err := r.db.Find(&cases).Error; err != nil {
+		log.Printf("加载案例库失败: %v", err)
+		return
+	}
+	log.Printf("BM25 索引已加载 %d 个案例", r.index(cases))
+}
+
+// index 用案例重建倒排索引，返回索引的案例数 (没有检索词的案例不入索引)
+func (r *BM25Retriever) index(cases []common.Case) int {
+	docs := make([]bm25Doc, 0, len(cases))
+	postings := make(map[string][]int)
+	total := 0
+	for _, c := range cases {
+		tokens := tokenize(c.Content)
+		if len(tokens) == 0 {
+			continue
+		}
+		tf := make(map[string]int)
+		for _, t := range tokens {
+			tf[t]++
+		}
+		for t := range tf {
+			postings[t] = append(postings[t], len(docs))
+		}
+		docs = append(docs, bm25Doc{c: c, tf: tf, length: len(tokens)})
+		total += len(tokens)
+	}
+
+	avgLen := 0.0
+	if len(docs) > 0 {
+		avgLen = float64(total) / float64(len(docs))
+	}
+	r.mu.Lock()
+	r.docs = docs
+	r.postings = postings
+	r.avgLen = avgLen
+	r.lastRefresh = time.Now()
+	r.mu.Unlock()
+	return len(docs)
+}
+
+// Reload 立即重建索引 (案例变更通知到达时调用)
+func (r *BM25Retriever) Reload() {
+	r.load()
+}
+
+func (r *BM25Retriever) refreshLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	for range ticker.C {
+		r.load()
+	}
+}
+
+// Retrieve 按 BM25 得分返回最相关的案例，支持 WithCaseFilter 过滤
+func (r *BM25Retriever) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	topK := defaultRetrieveTopK
+	options := retriever.GetCommonOptions(&retriever.Options{TopK: &topK}, opts...)
+	if options.TopK != nil {
+		topK = *options.TopK
+	}
+
+	filter := caseFilterFrom(opts)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	n := float64(len(r.docs))
+	scores := make(map[int]float64)
+	seen := make(map[string]bool)
+	for _, t := range tokenize(query) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		posting := r.postings[t]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, i := range posting {
+			d := r.docs[i]
+			if !filter.match(d.c.AppID, d.c.Label, d.c.Category) {
+				continue
+			}
+			tf := float64(d.tf[t])
+			norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(d.length)/r.avgLen))
+			scores[i] += idf * norm
+		}
+	}
+
+	ranked := make([]int, 0, len(scores))
+	for i := range scores {
+		ranked = append(ranked, i)
+	}
+	sort.Slice(ranked, func(a, b int) bool {
+		if scores[ranked[a]] != scores[ranked[b]] {
+			return scores[ranked[a]] > scores[ranked[b]]
+		}
+		return ranked[a] < ranked[b]
+	})
+	if len(ranked) > topK {
+		ranked = ranked[:topK]
+	}
+
+	docs := make([]*schema.Document, 0, len(ranked))
+	for _, i := range ranked {
+		c := r.docs[i].c
+		doc := &schema.Document{
+			ID:      strconv.FormatUint(uint64(c.ID), 10),
+			Content: c.Content,
+			MetaData: map[string]any{
+				"label":    c.Label,
+				"category": c.Category,
+				"case_id":  int64(c.ID),
+				"app_id":   c.AppID,
+			},
+		}
+		docs = append(docs, doc.WithScore(scores[i]))
+	}
+	return docs, nil
+}
+
+// tokenize 将文本切分为检索词
+// 中日韩文字按相邻两字 (bigram) 切分，孤立的单字保留为单字；其他文字按字母数字连续段切分为单词
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch {
+		case len(han) == 1:
+			tokens = append(tokens, string(han))
+		case len(han) > 1:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range NormalizeContent(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
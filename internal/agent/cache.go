@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// CacheStats 是缓存的命中统计
+type CacheStats struct {
+	Size      int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRate 返回命中率 (0~1)
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type cacheEntry struct {
+	key       string
+	verdict   Verdict
+	expiresAt time.Time
+}
+
+// VerdictCache 是带过期时间的 LRU 结论缓存
+// 容量或 TTL 为 0 时缓存被禁用
+type VerdictCache struct {
+	capacity int
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	stats CacheStats
+}
+
+// NewVerdictCache 创建结论缓存
+func NewVerdictCache(capacity int, ttl time.Duration) *VerdictCache {
+	return &VerdictCache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *VerdictCache) enabled() bool {
+	return c != nil && c.capacity > 0 && c.ttl > 0
+}
+
+// Get 查询缓存，过期条目视为未命中并被移除
+func (c *VerdictCache) Get(key string) (*Verdict, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(el)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	v := entry.verdict
+	return &v, true
+}
+
+// Put 写入缓存，超出容量时淘汰最久未使用的条目
+func (c *VerdictCache) Put(key string, v *Verdict) {
+	if !c.enabled() || v == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.verdict = *v
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, verdict: *v, expiresAt: expiresAt})
+	for c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Purge 清空缓存 (策略变更时调用)
+func (c *VerdictCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.mu.Unlock()
+}
+
+// Stats 返回当前统计信息
+func (c *VerdictCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Size = c.ll.Len()
+	return s
+}
+
+func (c *VerdictCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// CacheKey 根据归一化内容和策略版本生成缓存键
+// policyVersion 应包含提示词版本与模型版本，版本变化后旧结论自然失效
+func CacheKey(content, scene, language, policyVersion string) string {
+	h := sha256.New()
+	h.Write([]byte(NormalizeContent(content)))
+	for _, part := range []string{scene, language, policyVersion} {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NormalizeContent 归一化文本: 全角转半角、转小写、合并空白
+// 使 "你好  World" 与 "你好 world" 命中同一条缓存
+func NormalizeContent(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	space := false
+	for _, r := range s {
+		switch {
+		case r == 0x3000: // 全角空格
+			r = ' '
+		case r >= 0xFF01 && r <= 0xFF5E: // 全角 ASCII
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		space = false
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
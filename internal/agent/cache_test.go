@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeContent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"全角字母数字转半角", "ＡＢＣ１２３", "abc123"},
+		{"全角标点转半角", "你好！？", "你好!?"},
+		{"全角空格视为空白", "你好　世界", "你好 世界"},
+		{"合并连续空白", "hello  \t\n world", "hello world"},
+		{"去除首尾空白", "  hi  ", "hi"},
+		{"转小写", "Hello World", "hello world"},
+		{"中文不变", "今天天气很好", "今天天气很好"},
+		{"空字符串", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeContent(tt.in); got != tt.want {
+				t.Fatalf("NormalizeContent(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	base := CacheKey("你好  World", "im", "zh", "v1")
+	tests := []struct {
+		name     string
+		key      string
+		wantSame bool
+	}{
+		{"归一化后相同的内容", CacheKey("你好 ｗｏｒｌｄ", "im", "zh", "v1"), true},
+		{"内容不同", CacheKey("你好 world!", "im", "zh", "v1"), false},
+		{"场景不同", CacheKey("你好 world", "comment", "zh", "v1"), false},
+		{"语言不同", CacheKey("你好 world", "im", "en", "v1"), false},
+		{"策略版本不同", CacheKey("你好 world", "im", "zh", "v2"), false},
+		{"字段边界不能混淆", CacheKey("你好 world", "imz", "h", "v1"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.key == base) != tt.wantSame {
+				t.Fatalf("key == base: %v, want %v", tt.key == base, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestVerdictCacheLRU(t *testing.T) {
+	c := NewVerdictCache(2, time.Minute)
+	c.Put("a", &Verdict{Action: "allow"})
+	c.Put("b", &Verdict{Action: "block"})
+	if _, ok := c.Get("a"); !ok { // a 成为最近使用
+		t.Fatal("Get(a) miss")
+	}
+	c.Put("c", &Verdict{Action: "review"}) // 淘汰最久未使用的 b
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b 应被淘汰")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("Get(%s) miss", key)
+		}
+	}
+	s := c.Stats()
+	if s.Size != 2 || s.Evictions != 1 || s.Hits != 3 || s.Misses != 1 {
+		t.Fatalf("Stats() = %+v", s)
+	}
+
+	// 返回副本，修改不影响缓存
+	v, _ := c.Get("a")
+	v.Action = "block"
+	if v, _ := c.Get("a"); v.Action != "allow" {
+		t.Fatalf("缓存条目被修改: %s", v.Action)
+	}
+}
+
+func TestVerdictCacheTTL(t *testing.T) {
+	c := NewVerdictCache(10, 20*time.Millisecond)
+	c.Put("a", &Verdict{Action: "block"})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get(a) miss before expiry")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("过期条目不应命中")
+	}
+	if s := c.Stats(); s.Size != 0 {
+		t.Fatalf("过期条目应被移除, size = %d", s.Size)
+	}
+}
+
+func TestVerdictCacheDisabled(t *testing.T) {
+	for _, c := range []*VerdictCache{nil, NewVerdictCache(0, time.Minute), NewVerdictCache(10, 0)} {
+		c.Put("a", &Verdict{Action: "block"})
+		if _, ok := c.Get("a"); ok {
+			t.Fatal("禁用的缓存不应命中")
+		}
+	}
+}
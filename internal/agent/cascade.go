@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	ark_model "github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+// 审核层级 (Verdict.Tier)
+const (
+	// TierFast 小模型快速初审直接给出的结论
+	TierFast = "fast"
+	// TierFull 大模型 (完整 ReAct 工具循环或集成) 给出的结论
+	TierFull = "full"
+)
+
+// cascadeHint 追加在快速初审系统提示词末尾的说明
+const cascadeHint = "\n\n当前为快速初审，不可使用工具，请直接给出结论。confidence 需如实反映把握程度，无法确定时请给出较低的值。"
+
+// cascade 是级联路由的第一层: 不带工具的小模型
+// 置信度低于阈值、结论为 review 或类别属于高风险时升级到完整 Agent
+type cascade struct {
+	modelID   string
+	runnable  compose.Runnable[[]*schema.Message, *schema.Message]
+	threshold float64
+	escalate  map[string]bool // 总是升级的高风险类别
+}
+
+// newCascade 根据配置创建快速初审层，未配置 CASCADE_MODEL_ID 时返回 nil
+func newCascade(ctx context.Context, cfg *common.Config) (*cascade, error) {
+	if cfg.CascadeModelID == "" {
+		return nil, nil
+	}
+	chatModel, err := ark_model.NewChatModel(ctx, &ark_model.ChatModelConfig{
+		APIKey: cfg.ArkAPIKey,
+		Model:  cfg.CascadeModelID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	runnable, err := buildGuardGraph(ctx, chatModel)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cascade{
+		modelID:   cfg.CascadeModelID,
+		runnable:  runnable,
+		threshold: cfg.CascadeThreshold,
+		escalate:  make(map[string]bool),
+	}
+	for _, category := range splitList(cfg.CascadeEscalateCategories) {
+		c.escalate[category] = true
+	}
+	log.Printf("[EinoAgent] 级联路由已启用: 初审模型=%s, 阈值=%.2f", c.modelID, c.threshold)
+	return c, nil
+}
+
+// tag 返回级联配置标识，用于策略版本
+func (c *cascade) tag() string {
+	return fmt.Sprintf("cascade(%s:%.2f)", c.modelID, c.threshold)
+}
+
+// run 使用小模型对一段文本执行快速初审
+func (c *cascade) run(ctx context.Context, prompts *PromptStore, req *Request, content string, opts ...compose.Option) (*Verdict, error) {
+	systemPrompt, promptVersion := prompts.Render(req.AppID, PromptScene(req.Scene, req.Mode), req.Language)
+	resp, err := c.runnable.Invoke(ctx, []*schema.Message{
+		schema.SystemMessage(systemPrompt + cascadeHint),
+		schema.UserMessage(content),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	verdict := parseVerdict(resp.Content)
+	verdict.PromptVersion = promptVersion
+	return verdict, nil
+}
+
+// shouldEscalate 判断初审结论是否需要交给完整 Agent
+func (c *cascade) shouldEscalate(v *Verdict) bool {
+	return v.Degraded || v.Action == "review" || v.Confidence < c.threshold || c.escalate[v.Category]
+}
+
+// tierStats 记录单个层级的调用次数与累计耗时
+type tierStats struct {
+	count atomic.Int64
+	nanos atomic.Int64
+}
+
+func (t *tierStats) observe(d time.Duration) {
+	t.count.Add(1)
+	t.nanos.Add(int64(d))
+}
+
+func (t *tierStats) avgLatencyMs() float64 {
+	n := t.count.Load()
+	if n == 0 {
+		return 0
+	}
+	return float64(t.nanos.Load()) / float64(n) / float64(time.Millisecond)
+}
+
+// cascadeStats 级联路由的运行指标
+type cascadeStats struct {
+	fast        tierStats
+	full        tierStats
+	escalations atomic.Int64 // 初审后升级到完整 Agent 的次数 (含初审失败)
+}
+
+// runTiered 先经快速初审，必要时升级到完整 Agent
+func (a *EinoAgent) runTiered(ctx context.Context, req *Request, content string) (*Verdict, error) {
+	if a.cascade != nil && !isGuardMode(req.Mode) {
+		start := time.Now()
+		verdict, err := a.cascade.run(ctx, a.prompts, req, content, compose.WithCallbacks(a.tracer))
+		a.stats.fast.observe(time.Since(start))
+		if err == nil && !a.cascade.shouldEscalate(verdict) {
+			verdict.Tier = TierFast
+			return verdict, nil
+		}
+		if err != nil {
+			log.Printf("[EinoAgent] 快速初审失败，升级到完整 Agent: ID=%s, err=%v", req.RequestID, err)
+		}
+		a.stats.escalations.Add(1)
+	}
+
+	start := time.Now()
+	verdict, err := a.runOnce(ctx, req, content)
+	a.stats.full.observe(time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	verdict.Tier = TierFull
+	return verdict, nil
+}
+
+// Stats 返回 Agent 的运行指标 (各层级请求数、平均耗时和升级率)
+func (a *EinoAgent) Stats() map[string]float64 {
+	metrics := map[string]float64{
+		"tier.full.requests":       float64(a.stats.full.count.Load()),
+		"tier.full.avg_latency_ms": a.stats.full.avgLatencyMs(),
+	}
+	if a.cascade != nil {
+		fast := a.stats.fast.count.Load()
+		metrics["tier.fast.requests"] = float64(fast)
+		metrics["tier.fast.avg_latency_ms"] = a.stats.fast.avgLatencyMs()
+		metrics["cascade.escalations"] = float64(a.stats.escalations.Load())
+		if fast > 0 {
+			metrics["cascade.escalation_rate"] = float64(a.stats.escalations.Load()) / float64(fast)
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Chunk 是长文本切分后的一个片段，Start/End 为在原文中的字符 (rune) 偏移
+type Chunk struct {
+	Index int
+	Start int
+	End   int
+	Text  string
+}
+
+// isSentenceEnd 判断字符是否为句子结束符 (兼容中英文标点)
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '…', '!', '?', ';', '\n':
+		return true
+	}
+	return false
+}
+
+// splitSentences 按句子切分文本，返回每个句子的 [start, end) 字符偏移
+// 英文句号仅在其后为空白时视为句子结束，避免切开小数和网址
+func splitSentences(text []rune) [][2]int {
+	var spans [][2]int
+	start := 0
+	for i, r := range text {
+		end := isSentenceEnd(r)
+		if r == '.' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n') {
+			end = true
+		}
+		if !end {
+			continue
+		}
+		// 连续的结束符 (如 "？！"、"\n\n") 归入同一句
+		if i+1 < len(text) && isSentenceEnd(text[i+1]) {
+			continue
+		}
+		spans = append(spans, [2]int{start, i + 1})
+		start = i + 1
+	}
+	if start < len(text) {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// SplitChunks 将长文本切分为带重叠的片段
+// 优先在句子/段落边界切分，单个句子超过 size 时强制按长度切分；
+// 相邻片段之间保留约 overlap 个字符的上下文，避免违规内容恰好被切开
+func SplitChunks(content string, size, overlap int) []Chunk {
+	text := []rune(content)
+	if size <= 0 || len(text) <= size {
+		return []Chunk{{Index: 0, Start: 0, End: len(text), Text: content}}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	// 将超长句子拆成不超过 size 的小段
+	var units [][2]int
+	for _, s := range splitSentences(text) {
+		for s[1]-s[0] > size {
+			units = append(units, [2]int{s[0], s[0] + size})
+			s[0] += size
+		}
+		units = append(units, s)
+	}
+
+	var chunks []Chunk
+	for i := 0; i < len(units); {
+		start, end := units[i][0], units[i][1]
+		j := i + 1
+		for j < len(units) && units[j][1]-start <= size {
+			end = units[j][1]
+			j++
+		}
+		chunks = append(chunks, Chunk{Index: len(chunks), Start: start, End: end, Text: string(text[start:end])})
+		if j >= len(units) {
+			break
+		}
+
+		// 下一个片段从末尾若干句开始，形成 overlap 字符以内的重叠，
+		// 同时保证下一个片段至少能容纳一个新句子
+		next := j
+		for next-1 > i && end-units[next-1][0] <= overlap && units[j][1]-units[next-1][0] <= size {
+			next--
+		}
+		i = next
+	}
+	return chunks
+}
+
+// actionSeverity 返回动作的严重程度，用于聚合多个片段的结论
+func actionSeverity(action string) int {
+	switch action {
+	case "block":
+		return 3
+	case "review":
+		return 2
+	case "rewrite":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// runChunked 并发审核各个片段，并将片段结论聚合为一个整体结论
+// 整体结论取最严重的片段结论，理由中注明触发的片段位置
+func (a *EinoAgent) runChunked(ctx context.Context, req *Request, chunks []Chunk) (*Verdict, error) {
+	verdicts := make([]*Verdict, len(chunks))
+	errs := make([]error, len(chunks))
+
+	// 有界工作池，避免单个长文占满模型并发配额
+	workers := a.chunkWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			c := chunks[i]
+			text := fmt.Sprintf("(以下为长文第 %d/%d 段，字符 %d-%d)\n%s", c.Index+1, len(chunks), c.Start, c.End, c.Text)
+			verdicts[i], errs[i] = a.runTiered(ctx, req, text)
+		}(i)
+	}
+	wg.Wait()
+	return mergeChunkVerdicts(chunks, verdicts, errs)
+}
+
+// mergeChunkVerdicts 将各片段的结论聚合为整体结论，errs[i] 不为空表示第 i 个片段审核失败
+// 所有片段都失败时返回第一个错误
+func mergeChunkVerdicts(chunks []Chunk, verdicts []*Verdict, errs []error) (*Verdict, error) {
+	var result *Verdict
+	var trigger Chunk
+	failed := 0
+	for i, v := range verdicts {
+		if errs[i] != nil {
+			failed++
+			// 片段审核失败视为需要复核
+			v = &Verdict{Action: "review", Reason: "片段审核失败: " + errs[i].Error(), Degraded: true}
+		}
+		if result == nil || actionSeverity(v.Action) > actionSeverity(result.Action) {
+			result, trigger = v, chunks[i]
+		}
+	}
+	if failed == len(chunks) {
+		return nil, errs[0]
+	}
+
+	out := *result
+	out.Reason = fmt.Sprintf("[第 %d/%d 段, 字符 %d-%d] %s", trigger.Index+1, len(chunks), trigger.Start, trigger.End, result.Reason)
+	// 违规片段取所有非放行片段的并集，由 Run 统一在原文中定位
+	out.Spans = nil
+	for _, v := range verdicts {
+		if v == nil || v.Degraded {
+			out.Degraded = true
+		}
+		if v != nil && v.Action != "allow" {
+			out.Spans = append(out.Spans, v.Spans...)
+		}
+	}
+	return &out, nil
+}
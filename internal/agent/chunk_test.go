@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		size    int
+		overlap int
+		want    []string
+	}{
+		{"短文本不切分", "你好。世界。", 10, 2, []string{"你好。世界。"}},
+		{"size 为 0 不切分", "一二三四五六", 0, 0, []string{"一二三四五六"}},
+		{"按句子边界切分", "第一句。第二句。第三句。", 8, 0, []string{"第一句。第二句。", "第三句。"}},
+		{"相邻片段重叠一句", "一一。二二。三三。四四。", 6, 3, []string{"一一。二二。", "二二。三三。", "三三。四四。"}},
+		{"超长句子按长度强切", "一二三四五六七八九十", 4, 0, []string{"一二三四", "五六七八", "九十"}},
+		{"连续结束符归入同一句", "真的吗？！好的。再见。", 6, 0, []string{"真的吗？！", "好的。再见。"}},
+		{"英文句号后为空白才切分", "Pi is 3.14 ok. Next one.", 16, 0, []string{"Pi is 3.14 ok.", " Next one."}},
+		{"重叠不小于 size 时忽略", "一一。二二。三三。", 6, 6, []string{"一一。二二。", "三三。"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := SplitChunks(tt.content, tt.size, tt.overlap)
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("SplitChunks() = %v, want %v", chunks, tt.want)
+			}
+			runes := []rune(tt.content)
+			for i, c := range chunks {
+				if c.Text != tt.want[i] || c.Index != i {
+					t.Fatalf("chunk %d = %+v, want %q", i, c, tt.want[i])
+				}
+				if string(runes[c.Start:c.End]) != c.Text {
+					t.Fatalf("chunk %d 偏移 [%d, %d) 与文本不一致", i, c.Start, c.End)
+				}
+				if tt.size > 0 && c.End-c.Start > tt.size {
+					t.Fatalf("chunk %d 长度 %d 超过 %d", i, c.End-c.Start, tt.size)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitChunksCoversLongCJKText(t *testing.T) {
+	content := strings.Repeat("这是一段很长的中文内容，没有句号", 20)
+	chunks := SplitChunks(content, 37, 5)
+	covered := 0
+	for _, c := range chunks {
+		if !utf8.ValidString(c.Text) {
+			t.Fatalf("chunk %d 切开了多字节字符", c.Index)
+		}
+		if c.Start > covered {
+			t.Fatalf("chunk %d 从 %d 开始，遗漏了 [%d, %d)", c.Index, c.Start, covered, c.Start)
+		}
+		covered = c.End
+	}
+	if covered != utf8.RuneCountInString(content) {
+		t.Fatalf("片段覆盖到 %d，原文 %d 字符", covered, utf8.RuneCountInString(content))
+	}
+}
+
+func TestMergeChunkVerdicts(t *testing.T) {
+	chunks := []Chunk{{Index: 0, Start: 0, End: 10}, {Index: 1, Start: 8, End: 20}, {Index: 2, Start: 18, End: 30}}
+	errFailed := errors.New("timeout")
+	tests := []struct {
+		name         string
+		verdicts     []*Verdict
+		errs         []error
+		wantAction   string
+		wantPrefix   string
+		wantSpans    int
+		wantDegraded bool
+		wantErr      bool
+	}{
+		{
+			name:       "取最严重的片段结论",
+			verdicts:   []*Verdict{{Action: "allow"}, {Action: "block", Reason: "违规", Spans: []Span{{Text: "x"}}}, {Action: "review", Spans: []Span{{Text: "y"}}}},
+			errs:       make([]error, 3),
+			wantAction: "block", wantPrefix: "[第 2/3 段, 字符 8-20] 违规", wantSpans: 2,
+		},
+		{
+			name:       "同等严重时取第一个",
+			verdicts:   []*Verdict{{Action: "review", Reason: "a"}, {Action: "review", Reason: "b"}, {Action: "allow"}},
+			errs:       make([]error, 3),
+			wantAction: "review", wantPrefix: "[第 1/3 段, 字符 0-10] a",
+		},
+		{
+			name:       "全部放行",
+			verdicts:   []*Verdict{{Action: "allow", Reason: "ok"}, {Action: "allow"}, {Action: "allow"}},
+			errs:       make([]error, 3),
+			wantAction: "allow", wantPrefix: "[第 1/3 段, 字符 0-10] ok",
+		},
+		{
+			name:       "片段失败按复核处理并标记降级",
+			verdicts:   []*Verdict{{Action: "allow"}, nil, {Action: "allow"}},
+			errs:       []error{nil, errFailed, nil},
+			wantAction: "review", wantPrefix: "[第 2/3 段, 字符 8-20] 片段审核失败", wantDegraded: true,
+		},
+		{
+			name:       "拦截优先于失败片段",
+			verdicts:   []*Verdict{{Action: "block", Reason: "违规"}, nil, {Action: "allow"}},
+			errs:       []error{nil, errFailed, nil},
+			wantAction: "block", wantPrefix: "[第 1/3 段", wantDegraded: true,
+		},
+		{
+			name:     "全部失败返回错误",
+			verdicts: make([]*Verdict, 3),
+			errs:     []error{errFailed, errFailed, errFailed},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := mergeChunkVerdicts(chunks, tt.verdicts, tt.errs)
+			if tt.wantErr {
+				if !errors.Is(err, errFailed) {
+					t.Fatalf("err = %v, want %v", err, errFailed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Action != tt.wantAction || !strings.HasPrefix(v.Reason, tt.wantPrefix) {
+				t.Fatalf("verdict = %s %q, want %s %q", v.Action, v.Reason, tt.wantAction, tt.wantPrefix)
+			}
+			if len(v.Spans) != tt.wantSpans || v.Degraded != tt.wantDegraded {
+				t.Fatalf("spans = %d, degraded = %v, want %d, %v", len(v.Spans), v.Degraded, tt.wantSpans, tt.wantDegraded)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Turn 是对话中的一条消息
+type Turn struct {
+	Role    string `json:"role"` // system, user, assistant
+	Content string `json:"content"`
+	UserID  string `json:"user_id,omitempty"` // 发送者 ID (IM 多人对话)
+}
+
+// Input 返回送入模型的待审核文本
+// 带有对话上下文时为带编号的对话记录，否则为原始内容
+func (r *Request) Input() string {
+	if len(r.Conversation) == 0 {
+		return r.Content
+	}
+	return formatConversation(r.Conversation, r.TargetIndex)
+}
+
+// formatConversation 将对话格式化为带编号的文本，并标明待审核的消息
+func formatConversation(turns []Turn, target int) string {
+	if target < 0 || target >= len(turns) {
+		target = len(turns) - 1
+	}
+	var sb strings.Builder
+	sb.WriteString("对话记录:\n")
+	for i, t := range turns {
+		speaker := t.Role
+		if t.UserID != "" {
+			speaker += "(" + t.UserID + ")"
+		}
+		fmt.Fprintf(&sb, "[%d] %s: %s\n", i, speaker, t.Content)
+	}
+	fmt.Fprintf(&sb, "\n待审核的是第 [%d] 条消息。", target)
+	return sb.String()
+}
@@ -2,25 +2,40 @@ package agent
 
 import (
 	"context"
-	"encoding/json"
 
 	"log"
 
 	ark_embed "github.com/cloudwego/eino-ext/components/embedding/ark"
 	ark_model "github.com/cloudwego/eino-ext/components/model/ark"
-	"github.com/cloudwego/eino-ext/components/retriever/milvus2"
-	"github.com/cloudwego/eino-ext/components/retriever/milvus2/search_mode"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/model"
+	einoretriever "github.com/cloudwego/eino/components/retriever"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
-	"github.com/milvus-io/milvus/client/v2/milvusclient"
 	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
 )
 
 // EinoAgent 封装了 Eino 运行图
 type EinoAgent struct {
-	runnable compose.Runnable[[]*schema.Message, *schema.Message]
+	members  []*member         // 参与审核的模型，多于一个时按 strategy 投票
+	strategy string            // 集成策略
+	modelTag string            // 模型配置标识 (单模型时为模型 ID)，用于策略版本
+	cascade  *cascade          // 快速初审层 (可能为 nil)
+	tracer   callbacks.Handler // 记录模型与工具调用轨迹的回调
+	lexical  *BM25Retriever    // 案例库词法索引 (可能为 nil)
+	stats    cascadeStats
+	prompts  *PromptStore
+	entities *EntityDictionary
+	embedder embedding.Embedder // 可能为空 (Embedding 初始化失败时)
+
+	// 长文本切分参数
+	chunkSize    int
+	chunkOverlap int
+	chunkWorkers int
 }
 
 // Arguments structs
@@ -35,7 +50,8 @@ type CheckPoliticalArgs struct {
 }
 
 // NewEinoAgent 初始化并构建 Eino Agent
-func NewEinoAgent(ctx context.Context, cfg *common.Config) (*EinoAgent, error) {
+// db 用于加载提示词模板，为空时使用内置提示词
+func NewEinoAgent(ctx context.Context, cfg *common.Config, db *gorm.DB) (*EinoAgent, error) {
 	// 1. 初始化 Embedding (用于 Retriever)
 	// 使用火山引擎 Ark Embedding 服务
 	emb, err := ark_embed.NewEmbedder(ctx, &ark_embed.EmbeddingConfig{
@@ -47,24 +63,33 @@ func NewEinoAgent(ctx context.Context, cfg *common.Config) (*EinoAgent, error) {
 	}
 
 	// 2. 初始化 Milvus Retriever (向量检索)
-	// 用于从向量数据库中检索相似的历史违规案例
-	var retriever *milvus2.Retriever
-	if emb != nil {
-		retriever, err = milvus2.NewRetriever(ctx, &milvus2.RetrieverConfig{
-			ClientConfig: &milvusclient.ClientConfig{
-				Address: cfg.MilvusAddr,
-			},
-			Collection: "sensitive_cases",                          // 集合名称
-			TopK:       3,                                          // 返回前 3 个最相似结果
-			SearchMode: search_mode.NewApproximate(milvus2.COSINE), // 使用余弦相似度
-			Embedding:  emb,                                        // 注入 Embedder
-		})
+	// 用于从向量数据库中检索相似的历史违规案例；集合定义与配置不一致时直接报错，避免静默返回错误结果
+	var vector *vectorRetriever
+	if emb != nil && cfg.RetrieverMode != RetrieverModeBM25 {
+		vector, err = newMilvusRetriever(ctx, cfg, emb)
 		if err != nil {
-			log.Printf("警告: 初始化 milvus retriever 失败: %v", err)
+			return nil, err
 		}
 	}
 
-	// 3. 定义工具 (Tools)
+	// 3. 组装案例检索: 向量检索与 MySQL 案例库上的 BM25 词法检索融合；
+	// Embedding 或 Milvus 不可用时仅使用 BM25，保证降级或离线部署下 RAG 仍可用
+	var lexical *BM25Retriever
+	if db != nil && cfg.RetrieverMode != RetrieverModeVector {
+		lexical = NewBM25Retriever(db)
+	}
+	var caseRetriever einoretriever.Retriever
+	switch {
+	case vector != nil && lexical != nil:
+		caseRetriever = newCaseSearcher(NewHybridRetriever(vector, lexical), cfg)
+	case vector != nil:
+		caseRetriever = newCaseSearcher(vector, cfg)
+	case lexical != nil:
+		log.Printf("警告: 向量检索不可用，案例检索仅使用 BM25")
+		caseRetriever = newCaseSearcher(lexical, cfg)
+	}
+
+	// 4. 定义工具 (Tools)
 
 	// 工具 1: 搜索敏感案例 (RAG)
 	searchInfo := &schema.ToolInfo{
@@ -79,25 +104,25 @@ func NewEinoAgent(ctx context.Context, cfg *common.Config) (*EinoAgent, error) {
 		}),
 	}
 	searchTool := utils.NewTool(searchInfo, func(ctx context.Context, args *SearchArgs) (string, error) {
-		if retriever == nil {
+		if caseRetriever == nil {
 			return "错误: Retriever 未初始化", nil
 		}
-		docs, err := retriever.Retrieve(ctx, args.Keyword)
+		docs, err := caseRetriever.Retrieve(ctx, args.Keyword)
 		if err != nil {
 			return "错误: " + err.Error(), nil
 		}
 		if len(docs) == 0 {
 			return "未找到相似案例。", nil
 		}
-		res, _ := json.Marshal(docs)
-		return string(res), nil
+		return formatCases(docs), nil
 	})
 
-	// 工具 2: 检查政治实体 (Mock)
-	// 这是一个示例工具，实际可以调用 NER 模型 API
+	// 工具 2: 检查政治实体
+	// 基于管理后台维护的敏感实体词典 (名称、别名、拼音) 进行匹配
+	entities := NewEntityDictionary(db)
 	politicalInfo := &schema.ToolInfo{
 		Name: "check_political_entities",
-		Desc: "检查文本是否提及特定的政治实体或敏感人物。",
+		Desc: "检查文本是否提及特定的政治实体或敏感人物，返回命中的实体及其类别和严重度。",
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
 			"text": {
 				Type:     schema.String,
@@ -107,16 +132,59 @@ func NewEinoAgent(ctx context.Context, cfg *common.Config) (*EinoAgent, error) {
 		}),
 	}
 	politicalTool := utils.NewTool(politicalInfo, func(ctx context.Context, args *CheckPoliticalArgs) (string, error) {
-		return "未发现敏感政治实体。", nil
+		return FormatEntityHits(entities.Match(tenantFrom(ctx), args.Text)), nil
 	})
 
 	tools := []tool.BaseTool{searchTool, politicalTool}
 
-	// 4. 初始化 Chat Model (Ark)
+	// 创建 ToolsNode (负责执行工具调用)，各模型共享
+	toolsNode, err := compose.NewToolNode(ctx, &compose.ToolsNodeConfig{
+		Tools: tools,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a := &EinoAgent{prompts: NewPromptStore(db), entities: entities, strategy: normalizeStrategy(cfg.EnsembleStrategy), tracer: newTraceHandler(),
+		chunkSize: cfg.ChunkSize, chunkOverlap: cfg.ChunkOverlap, chunkWorkers: cfg.ChunkWorkers}
+	if emb != nil {
+		a.embedder = emb
+	}
+	a.lexical = lexical
+
+	// 5. 为每个模型构建 Eino Graph
+	// 未配置 ENSEMBLE_MODELS 时只有 ARK_MODEL_ID 一个成员
+	specs := parseEnsembleMembers(cfg.EnsembleModels, cfg.ArkModelID)
+	for _, spec := range specs {
+		m, err := newMember(ctx, cfg, spec, tools, toolsNode, caseRetriever)
+		if err != nil {
+			return nil, err
+		}
+		a.members = append(a.members, m)
+	}
+	a.modelTag = ensembleTag(specs, a.strategy)
+	if len(a.members) > 1 {
+		log.Printf("[EinoAgent] 集成模式: %s", a.modelTag)
+	}
+
+	// 6. 级联路由: 小模型快速初审，低置信度或高风险类别时才调用上面的完整 Agent
+	a.cascade, err = newCascade(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if a.cascade != nil {
+		a.modelTag = a.cascade.tag() + ">" + a.modelTag
+	}
+	return a, nil
+}
+
+// newMember 为一个模型构建审核所需的各个图变体
+func newMember(ctx context.Context, cfg *common.Config, spec memberSpec, tools []tool.BaseTool, toolsNode *compose.ToolsNode, caseRetriever einoretriever.Retriever) (*member, error) {
+	// 初始化 Chat Model (Ark)
 	// 使用火山引擎 Ark 大语言模型服务
 	chatModel, err := ark_model.NewChatModel(ctx, &ark_model.ChatModelConfig{
 		APIKey: cfg.ArkAPIKey,
-		Model:  cfg.ArkModelID,
+		Model:  spec.modelID,
 	})
 	if err != nil {
 		return nil, err
@@ -137,17 +205,38 @@ func NewEinoAgent(ctx context.Context, cfg *common.Config) (*EinoAgent, error) {
 	}
 	toolModel := chatModel
 
-	// 5. 构建 Eino Graph (ReAct 模式)
-	// 节点：Model -> Tools
+	// 构建 Eino Graph (ReAct 模式)
+	// 编译两种图: 标准 ReAct 图，以及在首次调用模型前注入相似案例的 few-shot 变体
+	// 具体使用哪一种由场景对应提示词模板的 few_shot_k 决定
+	runnable, err := buildReActGraph(ctx, toolModel, toolsNode, nil)
+	if err != nil {
+		return nil, err
+	}
+	fewShotRunnable, err := buildReActGraph(ctx, toolModel, toolsNode, newFewShotLambda(caseRetriever))
+	if err != nil {
+		return nil, err
+	}
 
-	// 创建 ToolsNode (负责执行工具调用)
-	toolsNode, err := compose.NewToolNode(ctx, &compose.ToolsNodeConfig{
-		Tools: tools,
+	// 检测模式只判断输入意图，不需要检索案例或调用工具，使用单独的未绑定工具的模型实例
+	guardModel, err := ark_model.NewChatModel(ctx, &ark_model.ChatModelConfig{
+		APIKey: cfg.ArkAPIKey,
+		Model:  spec.modelID,
 	})
 	if err != nil {
 		return nil, err
 	}
+	guardRunnable, err := buildGuardGraph(ctx, guardModel)
+	if err != nil {
+		return nil, err
+	}
 
+	return &member{memberSpec: spec, runnable: runnable, fewShotRunnable: fewShotRunnable, guardRunnable: guardRunnable}, nil
+}
+
+// buildReActGraph 构建并编译 ReAct 图
+// 节点：[Few-shot] -> Model -> Tools -> Model ...
+// fewShot 不为空时，在 START 与 Model 之间插入案例注入节点
+func buildReActGraph(ctx context.Context, toolModel model.ToolCallingChatModel, toolsNode *compose.ToolsNode, fewShot *compose.Lambda) (compose.Runnable[[]*schema.Message, *schema.Message], error) {
 	// 创建图
 	g := compose.NewGraph[[]*schema.Message, *schema.Message]()
 
@@ -155,8 +244,14 @@ func NewEinoAgent(ctx context.Context, cfg *common.Config) (*EinoAgent, error) {
 	_ = g.AddChatModelNode("model", toolModel)
 	_ = g.AddToolsNode("tools", toolsNode)
 
-	// 添加边: Start -> Model
-	_ = g.AddEdge(compose.START, "model")
+	// 添加边: Start -> [Few-shot ->] Model
+	if fewShot != nil {
+		_ = g.AddLambdaNode("few_shot", fewShot)
+		_ = g.AddEdge(compose.START, "few_shot")
+		_ = g.AddEdge("few_shot", "model")
+	} else {
+		_ = g.AddEdge(compose.START, "model")
+	}
 
 	// 添加分支: Model -> Tools (如果模型决定调用工具) OR End (如果模型生成了最终回复)
 	branch := compose.NewGraphBranch(func(_ context.Context, msg *schema.Message) (string, error) {
@@ -172,23 +267,118 @@ func NewEinoAgent(ctx context.Context, cfg *common.Config) (*EinoAgent, error) {
 	_ = g.AddEdge("tools", "model")
 
 	// 编译图
-	runnable, err := g.Compile(ctx)
+	return g.Compile(ctx)
+}
+
+// buildGuardGraph 构建并编译检测模式使用的单轮图
+// 节点：Model
+func buildGuardGraph(ctx context.Context, chatModel model.BaseChatModel) (compose.Runnable[[]*schema.Message, *schema.Message], error) {
+	g := compose.NewGraph[[]*schema.Message, *schema.Message]()
+	_ = g.AddChatModelNode("model", chatModel)
+	_ = g.AddEdge(compose.START, "model")
+	_ = g.AddEdge("model", compose.END)
+	return g.Compile(ctx)
+}
+
+// Embedder 返回 Agent 使用的 Embedding 组件，未初始化时为 nil
+func (a *EinoAgent) Embedder() embedding.Embedder {
+	return a.embedder
+}
+
+// PolicyVersion 返回处理该请求时生效的策略版本 (提示词版本@模型版本)
+// 用于结论缓存的键，版本变化后旧缓存不再命中；检测模式带有模式前缀，与内容审核结论互不命中；
+// 各应用的策略 (规则、案例、词典) 不同，带有应用前缀，结论不跨应用复用
+func (a *EinoAgent) PolicyVersion(req *Request) string {
+	version := a.prompts.Version(req.AppID, PromptScene(req.Scene, req.Mode)) + "@" + a.modelTag
+	if isGuardMode(req.Mode) {
+		version = req.Mode + "/" + version
+	}
+	if req.AppID != "" {
+		version = req.AppID + ":" + version
+	}
+	return version
+}
+
+// isGuardMode 判断是否为内容审核以外的检测模式
+func isGuardMode(mode string) bool {
+	return mode != "" && mode != common.ScanModeContent
+}
+
+// ReloadPolicy 立即重新加载提示词、实体词典等策略配置
+func (a *EinoAgent) ReloadPolicy() {
+	a.prompts.Reload()
+	a.entities.Reload()
+	if a.lexical != nil {
+		a.lexical.Reload()
+	}
+}
+
+// Run 执行 Agent 逻辑
+// 超过 CHUNK_SIZE 的长文本会被切分为多个片段并发审核后聚合
+func (a *EinoAgent) Run(ctx context.Context, req *Request) (*Verdict, error) {
+	log.Printf("[EinoAgent] 收到审核内容: ID=%s, App=%s, Scene=%s, Mode=%s, Content=%s", req.RequestID, req.AppID, req.Scene, req.Mode, req.Content)
+
+	// 工具调用与案例检索只使用全局和该应用的数据
+	ctx = withTenant(ctx, req.AppID)
+
+	// 记录本次请求所有模型调用与工具调用的轨迹
+	trace := &Trace{}
+	ctx = withTrace(ctx, trace)
+
+	var verdict *Verdict
+	var err error
+	if len(req.Conversation) > 0 {
+		// 带对话上下文的请求需要整体判断 (且改写文本必须覆盖完整回复)，不做切分
+		verdict, err = a.runTiered(ctx, req, req.Input())
+	} else if chunks := SplitChunks(req.Content, a.chunkSize, a.chunkOverlap); len(chunks) > 1 {
+		log.Printf("[EinoAgent] 长文本切分为 %d 段: ID=%s", len(chunks), req.RequestID)
+		verdict, err = a.runChunked(ctx, req, chunks)
+	} else {
+		verdict, err = a.runTiered(ctx, req, req.Content)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &EinoAgent{runnable: runnable}, nil
+	// 违规片段以待审核内容为准重新定位，丢弃原文中不存在的片段
+	verdict.Spans = LocateSpans(req.Content, verdict.Spans)
+	verdict.Trace = trace.Steps()
+	log.Printf("[EinoAgent] 审核完成: ID=%s, Action=%s, Tier=%s, Steps=%d", req.RequestID, verdict.Action, verdict.Tier, len(verdict.Trace))
+	return verdict, nil
 }
 
-// Run 执行 Agent 逻辑
-func (a *EinoAgent) Run(ctx context.Context, content string) (string, error) {
-	log.Printf("[EinoAgent] 收到审核内容: %s", content)
+// runOnce 对一段文本执行一次审核，集成模式下由所有成员并发审核后投票
+func (a *EinoAgent) runOnce(ctx context.Context, req *Request, content string) (*Verdict, error) {
+	if len(a.members) == 1 {
+		return a.members[0].run(ctx, a.prompts, req, content, compose.WithCallbacks(a.tracer))
+	}
+	return a.runEnsemble(ctx, req, content)
+}
+
+// run 使用该成员的模型对一段文本执行一次完整的 Agent 图
+func (m *member) run(ctx context.Context, prompts *PromptStore, req *Request, content string, opts ...compose.Option) (*Verdict, error) {
+	// 按场景 (检测模式下为模式名) 选择生效的提示词模板；成员可指定固定的提示词场景
+	scene := PromptScene(req.Scene, req.Mode)
+	if m.scene != "" && !isGuardMode(req.Mode) {
+		scene = m.scene
+	}
+	systemPrompt, promptVersion := prompts.Render(req.AppID, scene, req.Language)
+	ctx = withSceneCategories(ctx, prompts.Taxonomy(req.AppID, scene))
+
+	// 检测模式使用不带工具的单轮图；内容审核场景开启 few-shot 时使用注入案例示例的图
+	runnable := m.runnable
+	if isGuardMode(req.Mode) {
+		runnable = m.guardRunnable
+	} else if k := prompts.FewShotK(req.AppID, scene); k > 0 {
+		runnable = m.fewShotRunnable
+		ctx = withFewShotK(ctx, k)
+	}
 
 	// 构造输入消息
 	input := []*schema.Message{
 		{
 			Role:    schema.System,
-			Content: "你是一个内容安全审核员。请分析用户的输入。用户输入可能包含中文、英文或其他语言。请不要认为它们是乱码，请仔细分析其语义，遇到不熟悉的名称和事件需要搜索相关资讯来判断。\n如有必要，请使用工具。\n\n请严格以 JSON 格式回复，不要包含 markdown 标记：\n{\"action\": \"allow\"|\"block\"|\"review\", \"reason\": \"简短说明原因\"}。",
+			Content: systemPrompt,
 		},
 		{
 			Role:    schema.User,
@@ -197,10 +387,12 @@ func (a *EinoAgent) Run(ctx context.Context, content string) (string, error) {
 	}
 
 	// 调用图
-	resp, err := a.runnable.Invoke(ctx, input)
+	resp, err := runnable.Invoke(ctx, input, opts...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return resp.Content, nil
+	verdict := parseVerdict(resp.Content)
+	verdict.PromptVersion = promptVersion
+	return verdict, nil
 }
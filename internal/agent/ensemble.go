@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 集成策略 (ENSEMBLE_STRATEGY)
+const (
+	// StrategyMajority 多数票决定，平票时取更严重的结论
+	StrategyMajority = "majority"
+	// StrategyUnanimous 拦截需全体成员一致，否则取其余成员中最严重的结论，用于降低误杀
+	StrategyUnanimous = "unanimous"
+	// StrategyAnyBlock 任一成员拦截即拦截 (取最严重的结论)，用于降低漏放
+	StrategyAnyBlock = "any_block"
+	// StrategyEscalate 全体一致时采用该结论，出现分歧时转人工复核
+	StrategyEscalate = "escalate"
+)
+
+// normalizeStrategy 校验集成策略，未知策略退回 majority
+func normalizeStrategy(strategy string) string {
+	switch strategy {
+	case StrategyMajority, StrategyUnanimous, StrategyAnyBlock, StrategyEscalate:
+		return strategy
+	case "":
+		return StrategyMajority
+	}
+	log.Printf("警告: 未知的集成策略 %q，使用 %s", strategy, StrategyMajority)
+	return StrategyMajority
+}
+
+// memberSpec 描述一个集成成员: 模型 ID 及可选的固定提示词场景
+type memberSpec struct {
+	name    string // 成员名，格式为 模型ID[@场景]
+	modelID string
+	scene   string
+}
+
+// member 是一个集成成员及其编译好的图
+type member struct {
+	memberSpec
+	runnable        compose.Runnable[[]*schema.Message, *schema.Message]
+	fewShotRunnable compose.Runnable[[]*schema.Message, *schema.Message] // 注入相似案例示例的图变体
+	guardRunnable   compose.Runnable[[]*schema.Message, *schema.Message] // 不带工具的单轮图，用于提示词注入等检测模式
+}
+
+// MemberVerdict 记录集成成员各自的结论，便于事后分析
+type MemberVerdict struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+}
+
+// parseEnsembleMembers 解析 ENSEMBLE_MODELS 配置
+// 格式为逗号分隔的 模型ID[@场景]，如 "ep-a,ep-b,ep-a@strict"；模型 ID 为空时使用默认模型，
+// 同一模型搭配不同场景即为 "多提示词" 集成。未配置时只有默认模型一个成员
+func parseEnsembleMembers(spec, defaultModel string) []memberSpec {
+	var specs []memberSpec
+	for _, item := range splitList(spec) {
+		modelID, scene, _ := strings.Cut(item, "@")
+		modelID = strings.TrimSpace(modelID)
+		if modelID == "" {
+			modelID = defaultModel
+		}
+		specs = append(specs, memberSpec{name: item, modelID: modelID, scene: strings.TrimSpace(scene)})
+	}
+	if len(specs) == 0 {
+		specs = []memberSpec{{name: defaultModel, modelID: defaultModel}}
+	}
+	return specs
+}
+
+// ensembleTag 返回模型配置标识，单模型时为模型 ID
+func ensembleTag(specs []memberSpec, strategy string) string {
+	if len(specs) == 1 && specs[0].scene == "" {
+		return specs[0].modelID
+	}
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.name
+	}
+	return fmt.Sprintf("ensemble(%s:%s)", strategy, strings.Join(names, ","))
+}
+
+// runEnsemble 所有成员并发审核同一段文本，并按策略合并结论
+func (a *EinoAgent) runEnsemble(ctx context.Context, req *Request, content string) (*Verdict, error) {
+	verdicts := make([]*Verdict, len(a.members))
+	errs := make([]error, len(a.members))
+	var wg sync.WaitGroup
+	for i, m := range a.members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			verdicts[i], errs[i] = m.run(ctx, a.prompts, req, content, compose.WithCallbacks(a.tracer))
+		}(i, m)
+	}
+	wg.Wait()
+
+	return combineVerdicts(a.strategy, a.members, verdicts, errs)
+}
+
+// combineVerdicts 按策略合并成员结论
+// 运行失败的成员不参与投票，结果标记为降级；全部失败时返回第一个错误
+func combineVerdicts(strategy string, members []*member, verdicts []*Verdict, errs []error) (*Verdict, error) {
+	records := make([]MemberVerdict, len(members))
+	var valid []*Verdict
+	degraded := false
+	for i, m := range members {
+		records[i] = MemberVerdict{Name: m.name}
+		if errs[i] != nil {
+			records[i].Action = "review"
+			records[i].Error = errs[i].Error()
+			degraded = true
+			log.Printf("[EinoAgent] 集成成员 %s 运行失败: %v", m.name, errs[i])
+			continue
+		}
+		records[i].Action = verdicts[i].Action
+		records[i].Reason = verdicts[i].Reason
+		if verdicts[i].Degraded {
+			degraded = true
+		}
+		valid = append(valid, verdicts[i])
+	}
+	if len(valid) == 0 {
+		return nil, errs[0]
+	}
+
+	action := decideAction(strategy, valid)
+
+	// 以第一个给出相同结论的成员作为代表 (理由、改写文本)；违规片段取所有非放行成员的并集
+	var out Verdict
+	for _, v := range valid {
+		if v.Action == action {
+			out = *v
+			break
+		}
+	}
+	if out.Action == "" {
+		out = Verdict{Action: action, Reason: "集成成员结论不一致，转人工复核", PromptVersion: valid[0].PromptVersion}
+	}
+	out.Spans = nil
+	for _, v := range valid {
+		if v.Action != "allow" {
+			out.Spans = append(out.Spans, v.Spans...)
+		}
+	}
+	out.Members = records
+	out.Degraded = out.Degraded || degraded
+	return &out, nil
+}
+
+// decideAction 按策略从有效成员结论中决定最终动作
+func decideAction(strategy string, verdicts []*Verdict) string {
+	votes := make(map[string]int)
+	most, least := verdicts[0].Action, verdicts[0].Action
+	for _, v := range verdicts {
+		votes[v.Action]++
+		if actionSeverity(v.Action) > actionSeverity(most) {
+			most = v.Action
+		}
+		if actionSeverity(v.Action) < actionSeverity(least) {
+			least = v.Action
+		}
+	}
+
+	switch strategy {
+	case StrategyAnyBlock:
+		return most
+	case StrategyUnanimous:
+		if votes["block"] == len(verdicts) {
+			return "block"
+		}
+		// 未能一致拦截时，取非拦截结论中最严重的一个
+		action := least
+		for _, v := range verdicts {
+			if v.Action != "block" && actionSeverity(v.Action) > actionSeverity(action) {
+				action = v.Action
+			}
+		}
+		return action
+	case StrategyEscalate:
+		if len(votes) == 1 {
+			return most
+		}
+		return "review"
+	default: // StrategyMajority
+		action, count := "", 0
+		for _, v := range verdicts {
+			n := votes[v.Action]
+			if n > count || (n == count && actionSeverity(v.Action) > actionSeverity(action)) {
+				action, count = v.Action, n
+			}
+		}
+		return action
+	}
+}
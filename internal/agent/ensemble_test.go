@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+)
+
+func verdictsOf(actions ...string) []*Verdict {
+	out := make([]*Verdict, len(actions))
+	for i, a := range actions {
+		out[i] = &Verdict{Action: a}
+	}
+	return out
+}
+
+func TestDecideAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		actions  []string
+		want     string
+	}{
+		{"多数票", StrategyMajority, []string{"allow", "block", "allow"}, "allow"},
+		{"多数票平票取更严重", StrategyMajority, []string{"allow", "block"}, "block"},
+		{"多数票三方平票取最严重", StrategyMajority, []string{"allow", "review", "block"}, "block"},
+		{"多数票平票复核优先于放行", StrategyMajority, []string{"review", "allow", "allow", "review"}, "review"},
+		{"一致拦截", StrategyUnanimous, []string{"block", "block"}, "block"},
+		{"未一致拦截取其余最严重", StrategyUnanimous, []string{"block", "review", "allow"}, "review"},
+		{"未一致拦截且其余放行", StrategyUnanimous, []string{"block", "allow"}, "allow"},
+		{"任一拦截即拦截", StrategyAnyBlock, []string{"allow", "allow", "block"}, "block"},
+		{"任一拦截无拦截取最严重", StrategyAnyBlock, []string{"allow", "rewrite"}, "rewrite"},
+		{"升级策略一致时采用", StrategyEscalate, []string{"block", "block"}, "block"},
+		{"升级策略分歧时复核", StrategyEscalate, []string{"allow", "block"}, "review"},
+		{"单个成员", StrategyEscalate, []string{"allow"}, "allow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decideAction(tt.strategy, verdictsOf(tt.actions...)); got != tt.want {
+				t.Fatalf("decideAction(%s, %v) = %s, want %s", tt.strategy, tt.actions, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCombineVerdicts(t *testing.T) {
+	members := []*member{{memberSpec: memberSpec{name: "a"}}, {memberSpec: memberSpec{name: "b"}}, {memberSpec: memberSpec{name: "c"}}}
+	errFailed := errors.New("timeout")
+	tests := []struct {
+		name         string
+		strategy     string
+		verdicts     []*Verdict
+		errs         []error
+		wantAction   string
+		wantReason   string
+		wantSpans    int
+		wantDegraded bool
+		wantErr      bool
+	}{
+		{
+			name:       "代表取第一个给出相同结论的成员",
+			strategy:   StrategyMajority,
+			verdicts:   []*Verdict{{Action: "allow", Reason: "ok"}, {Action: "block", Reason: "b1", Spans: []Span{{Text: "x"}}}, {Action: "block", Reason: "b2", Spans: []Span{{Text: "y"}}}},
+			errs:       make([]error, 3),
+			wantAction: "block", wantReason: "b1", wantSpans: 2,
+		},
+		{
+			name:       "分歧转人工复核时使用统一理由",
+			strategy:   StrategyEscalate,
+			verdicts:   []*Verdict{{Action: "allow"}, {Action: "block"}, {Action: "allow"}},
+			errs:       make([]error, 3),
+			wantAction: "review", wantReason: "集成成员结论不一致，转人工复核",
+		},
+		{
+			name:       "失败成员不参与投票并标记降级",
+			strategy:   StrategyMajority,
+			verdicts:   []*Verdict{nil, {Action: "allow", Reason: "ok"}, {Action: "allow"}},
+			errs:       []error{errFailed, nil, nil},
+			wantAction: "allow", wantReason: "ok", wantDegraded: true,
+		},
+		{
+			name:       "失败成员不影响一致拦截",
+			strategy:   StrategyUnanimous,
+			verdicts:   []*Verdict{{Action: "block", Reason: "b"}, nil, {Action: "block"}},
+			errs:       []error{nil, errFailed, nil},
+			wantAction: "block", wantReason: "b", wantDegraded: true,
+		},
+		{
+			name:     "全部失败返回错误",
+			strategy: StrategyMajority,
+			verdicts: make([]*Verdict, 3),
+			errs:     []error{errFailed, errFailed, errFailed},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := combineVerdicts(tt.strategy, members, tt.verdicts, tt.errs)
+			if tt.wantErr {
+				if !errors.Is(err, errFailed) {
+					t.Fatalf("err = %v, want %v", err, errFailed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Action != tt.wantAction || v.Reason != tt.wantReason {
+				t.Fatalf("verdict = %s %q, want %s %q", v.Action, v.Reason, tt.wantAction, tt.wantReason)
+			}
+			if len(v.Spans) != tt.wantSpans || v.Degraded != tt.wantDegraded {
+				t.Fatalf("spans = %d, degraded = %v, want %d, %v", len(v.Spans), v.Degraded, tt.wantSpans, tt.wantDegraded)
+			}
+			if len(v.Members) != len(members) {
+				t.Fatalf("members = %v", v.Members)
+			}
+			for i, rec := range v.Members {
+				if tt.errs[i] != nil && (rec.Action != "review" || rec.Error == "") {
+					t.Fatalf("失败成员记录 = %+v", rec)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeStrategy(t *testing.T) {
+	for in, want := range map[string]string{"": StrategyMajority, "bogus": StrategyMajority, StrategyAnyBlock: StrategyAnyBlock, StrategyEscalate: StrategyEscalate} {
+		if got := normalizeStrategy(in); got != want {
+			t.Errorf("normalizeStrategy(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/safeflow-project/safeflow/internal/common"
+	"gorm.io/gorm"
+)
+
+// EntityHit 是一次敏感实体命中
+type EntityHit struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Severity int    `json:"severity"`
+	Matched  string `json:"matched"` // 命中的名称、别名或拼音
+}
+
+// entityPattern 是词典中的一个待匹配词条
+type entityPattern struct {
+	entity  int    // 对应 entities 的下标
+	term    string // 原始词条
+	short   bool   // 是否为较短的纯字母数字词条 (需要边界检查，避免误命中单词内部)
+	runeLen int
+}
+
+// entityIndex 是一组词条编译出的 AC 自动机
+type entityIndex struct {
+	entities []common.SensitiveEntity
+	patterns []entityPattern
+	matcher  *acMatcher
+}
+
+// EntityDictionary 维护敏感实体词典，使用 AC 自动机进行多模式匹配
+// 名称、别名和拼音都会被归一化 (全角转半角、小写、去除空白和标点)，
+// 以识别 "习 近 平"、"Xi-Jinping" 等插入分隔符的变体。
+// 全局词条对所有应用生效，应用自己的词条只用于该应用的请求
+type EntityDictionary struct {
+	db          *gorm.DB
+	mu          sync.RWMutex
+	indexes     map[string]*entityIndex // 应用 ID -> 词典 ("" 为只含全局词条的词典)
+	lastRefresh time.Time
+}
+
+// NewEntityDictionary 创建词典并启动定期刷新
+// db 为空时词典为空
+func NewEntityDictionary(db *gorm.DB) *EntityDictionary {
+	d := &EntityDictionary{db: db, indexes: map[string]*entityIndex{"": newEntityIndex(nil)}}
+	if db != nil {
+		d.load()
+		go d.refreshLoop()
+	}
+	return d
+}
+
+func (d *EntityDictionary) load() {
+	var entities []common.SensitiveEntity
+	if err := d.db.Where("is_enabled = ?", true).Find(&entities).Error; err != nil {
+		log.Printf("加载敏感实体词典失败: %v", err)
+		return
+	}
+
+	var global []common.SensitiveEntity
+	tenants := make(map[string][]common.SensitiveEntity)
+	for _, e := range entities {
+		if e.AppID == "" {
+			global = append(global, e)
+		} else {
+			tenants[e.AppID] = append(tenants[e.AppID], e)
+		}
+	}
+	indexes := map[string]*entityIndex{"": newEntityIndex(global)}
+	for appID, own := range tenants {
+		indexes[appID] = newEntityIndex(append(own, global...))
+	}
+
+	d.mu.Lock()
+	d.indexes = indexes
+	d.lastRefresh = time.Now()
+	d.mu.Unlock()
+	log.Printf("已加载 %d 个敏感实体 (%d 个应用)", len(entities), len(tenants))
+}
+
+// newEntityIndex 归一化词条并构建 AC 自动机
+func newEntityIndex(entities []common.SensitiveEntity) *entityIndex {
+	var patterns []entityPattern
+	var terms []string
+	for i, e := range entities {
+		seen := make(map[string]bool)
+		for _, term := range entityTerms(&e) {
+			norm := compactText(term)
+			if norm == "" || seen[norm] {
+				continue
+			}
+			seen[norm] = true
+			runeLen := len([]rune(norm))
+			patterns = append(patterns, entityPattern{
+				entity:  i,
+				term:    term,
+				short:   runeLen < 4 && isASCIIAlnum(norm),
+				runeLen: runeLen,
+			})
+			terms = append(terms, norm)
+		}
+	}
+
+	return &entityIndex{entities: entities, patterns: patterns, matcher: newACMatcher(terms)}
+}
+
+// Reload 立即重新加载词典
+func (d *EntityDictionary) Reload() {
+	if d.db != nil {
+		d.load()
+	}
+}
+
+func (d *EntityDictionary) refreshLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	for range ticker.C {
+		d.load()
+	}
+}
+
+// Match 返回文本中出现的应用词条与全局词条，每个实体只返回一次，按严重度从高到低排序
+func (d *EntityDictionary) Match(appID, text string) []EntityHit {
+	d.mu.RLock()
+	idx, ok := d.indexes[appID]
+	if !ok {
+		idx = d.indexes[""]
+	}
+	d.mu.RUnlock()
+
+	source := []rune(NormalizeContent(text))
+	compact, pos := compactRunes(source)
+	found := make(map[int]EntityHit)
+	idx.matcher.scan(compact, func(pattern, end int) {
+		p := idx.patterns[pattern]
+		start := end - p.runeLen + 1
+		// 边界在归一化后的原文上检查，去除的空格和标点也算作边界
+		if p.short && !isWordBoundary(source, pos[start], pos[end]) {
+			return
+		}
+		if _, ok := found[p.entity]; ok {
+			return
+		}
+		e := idx.entities[p.entity]
+		found[p.entity] = EntityHit{Name: e.Name, Category: e.Category, Severity: e.Severity, Matched: p.term}
+	})
+
+	hits := make([]EntityHit, 0, len(found))
+	for _, h := range found {
+		hits = append(hits, h)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Severity != hits[j].Severity {
+			return hits[i].Severity > hits[j].Severity
+		}
+		return hits[i].Name < hits[j].Name
+	})
+	return hits
+}
+
+// FormatEntityHits 将命中结果格式化为工具输出
+func FormatEntityHits(hits []EntityHit) string {
+	if len(hits) == 0 {
+		return "未发现敏感政治实体。"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "发现 %d 个敏感实体:\n", len(hits))
+	for _, h := range hits {
+		fmt.Fprintf(&sb, "- %s (类别: %s, 严重度: %d, 命中词: %s)\n", h.Name, h.Category, h.Severity, h.Matched)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// entityTerms 返回实体的所有可匹配词条 (名称、别名、拼音)
+func entityTerms(e *common.SensitiveEntity) []string {
+	terms := []string{e.Name}
+	terms = append(terms, splitList(e.Aliases)...)
+	terms = append(terms, splitList(e.Pinyin)...)
+	return terms
+}
+
+// splitList 拆分逗号 (含中文逗号) 分隔的列表
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '，' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// compactText 归一化文本并去除所有非字母数字字符
+func compactText(s string) string {
+	var sb strings.Builder
+	for _, r := range NormalizeContent(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// compactRunes 去除归一化文本中的非字母数字字符，同时返回每个保留字符在 source 中的位置
+func compactRunes(source []rune) (compact []rune, pos []int) {
+	compact = make([]rune, 0, len(source))
+	pos = make([]int, 0, len(source))
+	for i, r := range source {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			compact = append(compact, r)
+			pos = append(pos, i)
+		}
+	}
+	return compact, pos
+}
+
+func isASCIIAlnum(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordBoundary 检查 [start, end] 两侧是否不是 ASCII 字母数字
+func isWordBoundary(text []rune, start, end int) bool {
+	isAlnum := func(r rune) bool { return r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) }
+	if start > 0 && isAlnum(text[start-1]) {
+		return false
+	}
+	if end+1 < len(text) && isAlnum(text[end+1]) {
+		return false
+	}
+	return true
+}
+
+// acMatcher 是 Aho-Corasick 多模式匹配自动机
+type acMatcher struct {
+	next []map[rune]int // 状态转移
+	fail []int          // 失败指针
+	out  [][]int        // 每个状态结束的模式下标
+}
+
+func newACMatcher(patterns []string) *acMatcher {
+	m := &acMatcher{
+		next: []map[rune]int{{}},
+		fail: []int{0},
+		out:  [][]int{nil},
+	}
+	// 构建 Trie
+	for i, p := range patterns {
+		state := 0
+		for _, r := range p {
+			nxt, ok := m.next[state][r]
+			if !ok {
+				nxt = len(m.next)
+				m.next = append(m.next, map[rune]int{})
+				m.fail = append(m.fail, 0)
+				m.out = append(m.out, nil)
+				m.next[state][r] = nxt
+			}
+			state = nxt
+		}
+		m.out[state] = append(m.out[state], i)
+	}
+	// BFS 构建失败指针
+	queue := make([]int, 0, len(m.next))
+	for _, s := range m.next[0] {
+		queue = append(queue, s)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range m.next[state] {
+			queue = append(queue, child)
+			f := m.fail[state]
+			for f > 0 {
+				if _, ok := m.next[f][r]; ok {
+					break
+				}
+				f = m.fail[f]
+			}
+			if nxt, ok := m.next[f][r]; ok && nxt != child {
+				m.fail[child] = nxt
+			}
+			m.out[child] = append(m.out[child], m.out[m.fail[child]]...)
+		}
+	}
+	return m
+}
+
+// scan 扫描文本，每命中一个模式调用一次 fn (参数为模式下标和结束位置)
+func (m *acMatcher) scan(text []rune, fn func(pattern, end int)) {
+	state := 0
+	for i, r := range text {
+		for state > 0 {
+			if _, ok := m.next[state][r]; ok {
+				break
+			}
+			state = m.fail[state]
+		}
+		if nxt, ok := m.next[state][r]; ok {
+			state = nxt
+		}
+		for _, p := range m.out[state] {
+			fn(p, i)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+func newTestDictionary(global []common.SensitiveEntity, tenants map[string][]common.SensitiveEntity) *EntityDictionary {
+	indexes := map[string]*entityIndex{"": newEntityIndex(global)}
+	for appID, own := range tenants {
+		indexes[appID] = newEntityIndex(append(own, global...))
+	}
+	return &EntityDictionary{indexes: indexes}
+}
+
+func TestEntityDictionaryMatch(t *testing.T) {
+	d := newTestDictionary([]common.SensitiveEntity{
+		{Name: "习近平", Aliases: "习大大", Pinyin: "xijinping,xjp", Category: "leader", Severity: 5},
+		{Name: "法轮功", Pinyin: "flg", Category: "organization", Severity: 4},
+		{Name: "六四", Aliases: "64", Category: "event", Severity: 3},
+	}, map[string][]common.SensitiveEntity{
+		"game": {{Name: "某游戏外挂", Aliases: "wg", Category: "cheat", Severity: 2}},
+	})
+
+	tests := []struct {
+		name  string
+		appID string
+		text  string
+		want  []string // 命中实体名称，按严重度从高到低
+	}{
+		{"中文名称", "", "关于习近平的新闻", []string{"习近平"}},
+		{"插入空格的变体", "", "习 近 平", []string{"习近平"}},
+		{"插入标点的拼音", "", "Xi-Jinping said", []string{"习近平"}},
+		{"全角字母", "", "ＸＪＰ", []string{"习近平"}},
+		{"句中的短拼音", "", "I hate xjp a lot", []string{"习近平"}},
+		{"被标点隔开的短拼音", "", "hello,xjp!", []string{"习近平"}},
+		{"单词内部的短拼音不命中", "", "axjpb", nil},
+		{"单词前缀的短拼音不命中", "", "xjpatterns", nil},
+		{"分隔符两侧拼成的短拼音不命中", "", "max jp", nil},
+		{"短数字别名", "", "纪念 64 周年", []string{"六四"}},
+		{"数字内部不命中", "", "价格 1645 元", nil},
+		{"短词条紧邻中文", "", "讨论flg问题", []string{"法轮功"}},
+		{"多个实体按严重度排序", "", "flg 与 xjp", []string{"习近平", "法轮功"}},
+		{"同一实体只返回一次", "", "习近平 xjp 习大大", []string{"习近平"}},
+		{"应用词条", "game", "卖 wg 了", []string{"某游戏外挂"}},
+		{"应用词条不影响其他应用", "shop", "卖 wg 了", nil},
+		{"应用同时使用全局词条", "game", "xjp", []string{"习近平"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := d.Match(tt.appID, tt.text)
+			if len(hits) != len(tt.want) {
+				t.Fatalf("Match(%q) = %v, want %v", tt.text, hits, tt.want)
+			}
+			for i, h := range hits {
+				if h.Name != tt.want[i] {
+					t.Fatalf("Match(%q)[%d] = %s, want %s", tt.text, i, h.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestACMatcherOverlapping(t *testing.T) {
+	m := newACMatcher([]string{"he", "she", "his", "hers"})
+	var got []int
+	m.scan([]rune("ushers"), func(pattern, end int) { got = append(got, pattern*10+end) })
+	// she 结束于 3，he 结束于 3，hers 结束于 5
+	want := map[int]bool{13: true, 3: true, 35: true}
+	if len(got) != len(want) {
+		t.Fatalf("scan = %v, want %v", got, want)
+	}
+	for _, g := range got {
+		if !want[g] {
+			t.Fatalf("scan = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxFewShotK 单次请求最多注入的示例数量
+const maxFewShotK = 10
+
+type fewShotKey struct{}
+
+// withFewShotK 在上下文中记录本次请求需要注入的示例数量
+func withFewShotK(ctx context.Context, k int) context.Context {
+	return context.WithValue(ctx, fewShotKey{}, k)
+}
+
+func fewShotKFrom(ctx context.Context) int {
+	k, _ := ctx.Value(fewShotKey{}).(int)
+	if k > maxFewShotK {
+		k = maxFewShotK
+	}
+	return k
+}
+
+// newFewShotLambda 创建 few-shot 注入节点
+// 在第一次调用模型之前，用完整的待审核内容检索 Top-K 相似案例，
+// 并以 "用户输入 / 审核结论" 消息对的形式插入到系统提示词之后，
+// 使模型的判定始终有案例参考，而不依赖模型自己决定是否调用搜索工具
+func newFewShotLambda(r retriever.Retriever) *compose.Lambda {
+	return compose.InvokableLambda(func(ctx context.Context, input []*schema.Message) ([]*schema.Message, error) {
+		k := fewShotKFrom(ctx)
+		if r == nil || k <= 0 {
+			return input, nil
+		}
+
+		// 找到待审核内容 (最后一条用户消息) 和系统提示词的结束位置
+		query, insertAt := "", 0
+		for i, msg := range input {
+			switch msg.Role {
+			case schema.System:
+				if insertAt == i {
+					insertAt = i + 1
+				}
+			case schema.User:
+				query = msg.Content
+			}
+		}
+		if query == "" {
+			return input, nil
+		}
+
+		docs, err := r.Retrieve(ctx, query, retriever.WithTopK(k))
+		if err != nil {
+			// 检索失败不影响审核，退化为零样本
+			log.Printf("[EinoAgent] few-shot 检索失败: %v", err)
+			return input, nil
+		}
+		examples := fewShotMessages(docs)
+		if len(examples) == 0 {
+			return input, nil
+		}
+
+		output := make([]*schema.Message, 0, len(input)+len(examples))
+		output = append(output, input[:insertAt]...)
+		output = append(output, examples...)
+		output = append(output, input[insertAt:]...)
+		return output, nil
+	})
+}
+
+// fewShotMessages 将检索到的案例转换为带标注的示例消息对
+func fewShotMessages(docs []*schema.Document) []*schema.Message {
+	var msgs []*schema.Message
+	for _, doc := range docs {
+		if doc.Content == "" {
+			continue
+		}
+		label, _ := doc.MetaData["label"].(string)
+		category, _ := doc.MetaData["category"].(string)
+
+		reason := fmt.Sprintf("参考案例: 知识库标注为 %s", label)
+		if category != "" {
+			reason += fmt.Sprintf(" (类别: %s)", category)
+		}
+		answer, _ := json.Marshal(Verdict{Action: labelToAction(label), Reason: reason})
+
+		msgs = append(msgs,
+			schema.UserMessage(doc.Content),
+			schema.AssistantMessage(string(answer), nil),
+		)
+	}
+	return msgs
+}
+
+// labelToAction 将案例标签映射为审核动作
+func labelToAction(label string) string {
+	switch label {
+	case "unsafe":
+		return "block"
+	case "safe":
+		return "allow"
+	default:
+		return "review"
+	}
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+)
+
+// rrfK 是倒数排名融合 (Reciprocal Rank Fusion) 的平滑常数
+const rrfK = 60
+
+// HybridRetriever 并发执行向量检索与词法检索，并用倒数排名融合合并结果
+// 任一路检索失败时退化为只使用另一路的结果，两路都失败时返回两路的错误
+type HybridRetriever struct {
+	vector  retriever.Retriever
+	lexical retriever.Retriever
+}
+
+// NewHybridRetriever 创建混合检索器
+func NewHybridRetriever(vector, lexical retriever.Retriever) *HybridRetriever {
+	return &HybridRetriever{vector: vector, lexical: lexical}
+}
+
+// Retrieve 返回融合排序后的 Top-K 案例，文档分数为 RRF 得分
+func (h *HybridRetriever) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	topK := defaultRetrieveTopK
+	options := retriever.GetCommonOptions(&retriever.Options{TopK: &topK}, opts...)
+	if options.TopK != nil {
+		topK = *options.TopK
+	}
+
+	// 每一路多取一些候选，融合后再截断
+	var results [2][]*schema.Document
+	var errs [2]error
+	var wg sync.WaitGroup
+	for i, r := range []retriever.Retriever{h.vector, h.lexical} {
+		wg.Add(1)
+		go func(i int, r retriever.Retriever) {
+			defer wg.Done()
+			results[i], errs[i] = r.Retrieve(ctx, query, append(opts, retriever.WithTopK(topK*2))...)
+		}(i, r)
+	}
+	wg.Wait()
+
+	if errs[0] != nil && errs[1] != nil {
+		return nil, errors.Join(errs[0], errs[1])
+	}
+	if errs[0] != nil {
+		log.Printf("[HybridRetriever] 向量检索失败，仅使用词法检索: %v", errs[0])
+	}
+	if errs[1] != nil {
+		log.Printf("[HybridRetriever] 词法检索失败，仅使用向量检索: %v", errs[1])
+	}
+
+	docs := fuseRRF(results[:]...)
+	if len(docs) > topK {
+		docs = docs[:topK]
+	}
+	return docs, nil
+}
+
+// fuseRRF 按倒数排名融合多路检索结果，同一内容的文档只保留一份
+func fuseRRF(lists ...[]*schema.Document) []*schema.Document {
+	scores := make(map[string]float64)
+	docs := make(map[string]*schema.Document)
+	var order []string
+	for _, list := range lists {
+		for rank, doc := range list {
+			key := NormalizeContent(doc.Content)
+			if _, ok := docs[key]; !ok {
+				docs[key] = doc
+				order = append(order, key)
+			}
+			scores[key] += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	out := make([]*schema.Document, 0, len(order))
+	for _, key := range order {
+		out = append(out, docs[key].WithScore(scores[key]))
+	}
+	return out
+}
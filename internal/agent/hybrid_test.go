@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+// listRetriever 返回固定的文档列表或错误
+type listRetriever struct {
+	contents []string
+	err      error
+}
+
+func (l listRetriever) Retrieve(context.Context, string, ...retriever.Option) ([]*schema.Document, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	docs := make([]*schema.Document, len(l.contents))
+	for i, c := range l.contents {
+		docs[i] = &schema.Document{Content: c}
+	}
+	return docs, nil
+}
+
+func (l listRetriever) mustRetrieve() []*schema.Document {
+	docs, _ := l.Retrieve(context.Background(), "")
+	return docs
+}
+
+func contentsOf(docs []*schema.Document) []string {
+	out := make([]string, len(docs))
+	for i, d := range docs {
+		out[i] = d.Content
+	}
+	return out
+}
+
+func TestFuseRRF(t *testing.T) {
+	docs := func(contents ...string) []*schema.Document { return listRetriever{contents: contents}.mustRetrieve() }
+	tests := []struct {
+		name  string
+		lists [][]*schema.Document
+		want  []string
+	}{
+		{"两路都命中的排在前面", [][]*schema.Document{docs("a", "b", "c"), docs("c", "d")}, []string{"c", "a", "b", "d"}},
+		{"同分时保持首次出现的顺序", [][]*schema.Document{docs("a", "b"), docs("b", "a")}, []string{"a", "b"}},
+		{"归一化后相同的内容只保留一份", [][]*schema.Document{docs("Hello  World"), docs("hello world", "x")}, []string{"Hello  World", "x"}},
+		{"一路为空", [][]*schema.Document{nil, docs("a", "b")}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fuseRRF(tt.lists...)
+			if fmt.Sprint(contentsOf(got)) != fmt.Sprint(tt.want) {
+				t.Fatalf("fuseRRF() = %v, want %v", contentsOf(got), tt.want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Score() > got[i-1].Score() {
+					t.Fatalf("结果未按 RRF 得分降序排列: %v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestHybridRetrieverFallback(t *testing.T) {
+	errVector := errors.New("milvus down")
+	errLexical := errors.New("bm25 down")
+	tests := []struct {
+		name    string
+		vector  listRetriever
+		lexical listRetriever
+		want    []string
+		wantErr []error
+	}{
+		{"融合两路并截断到 TopK", listRetriever{contents: []string{"a", "b"}}, listRetriever{contents: []string{"b", "c"}}, []string{"b", "a"}, nil},
+		{"向量检索失败时只用词法检索", listRetriever{err: errVector}, listRetriever{contents: []string{"c", "d"}}, []string{"c", "d"}, nil},
+		{"词法检索失败时只用向量检索", listRetriever{contents: []string{"a"}}, listRetriever{err: errLexical}, []string{"a"}, nil},
+		{"两路都失败时返回两个错误", listRetriever{err: errVector}, listRetriever{err: errLexical}, nil, []error{errVector, errLexical}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHybridRetriever(tt.vector, tt.lexical)
+			docs, err := h.Retrieve(context.Background(), "q", retriever.WithTopK(2))
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Fatalf("err = %v, want %v", err, want)
+				}
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(contentsOf(docs)) != fmt.Sprint(tt.want) {
+				t.Fatalf("Retrieve() = %v, want %v", contentsOf(docs), tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"今天天气", []string{"今天", "天天", "天气"}},
+		{"好", []string{"好"}},
+		{"Hello, World 2024", []string{"hello", "world", "2024"}},
+		{"加ＶＸ领奖", []string{"加", "vx", "领奖"}},
+		{"日本語テキスト", []string{"日本", "本語", "語テ", "テキ", "キス", "スト"}},
+		{"!!!", nil},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.in); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBM25Retrieve(t *testing.T) {
+	r := &BM25Retriever{}
+	n := r.index([]common.Case{
+		{ID: 1, Content: "加微信领取免费奖品", Label: "unsafe", Category: "spam"},
+		{ID: 2, Content: "今天天气很好", Label: "safe"},
+		{ID: 3, Content: "加微信看更多内容，加微信领奖", Label: "unsafe", Category: "spam", AppID: "shop"},
+		{ID: 4, Content: "!!!"},
+	})
+	if n != 3 {
+		t.Fatalf("index() = %d, want 3 (没有检索词的案例不入索引)", n)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		opts   []retriever.Option
+		wantID []string
+	}{
+		{"按得分排序", "加微信领奖", []retriever.Option{WithCaseFilter(CaseFilter{AppID: "shop"})}, []string{"3", "1"}},
+		{"其他应用的案例不可见", "加微信领奖", []retriever.Option{WithCaseFilter(CaseFilter{AppID: "game"})}, []string{"1"}},
+		{"按标签过滤", "天气 微信", []retriever.Option{WithCaseFilter(CaseFilter{Labels: []string{"safe"}})}, []string{"2"}},
+		{"TopK 截断", "加微信", []retriever.Option{retriever.WithTopK(1), WithCaseFilter(CaseFilter{AppID: "shop"})}, []string{"3"}},
+		{"没有共同检索词", "hello", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := r.Retrieve(context.Background(), tt.query, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, len(docs))
+			for i, d := range docs {
+				ids[i] = d.ID
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantID) {
+				t.Fatalf("Retrieve(%q) = %v, want %v", tt.query, ids, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/components/retriever/milvus2"
+	"github.com/cloudwego/eino-ext/components/retriever/milvus2/search_mode"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus/client/v2/entity"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+	"github.com/safeflow-project/safeflow/internal/common"
+	"github.com/safeflow-project/safeflow/internal/vectordb"
+)
+
+// maxCaseText 工具输出中每个案例保留的最大字符数
+const maxCaseText = 200
+
+// CaseFilter 案例检索的元数据过滤条件，同时作用于向量检索与 BM25 词法检索
+// 全局案例 (app_id 为空) 总是可见，应用的案例只对该应用可见
+type CaseFilter struct {
+	AppID      string   // 请求所属应用
+	Labels     []string // 只返回这些标签的案例，为空表示不限
+	Categories []string // 只返回这些类别的案例，为空表示不限
+}
+
+// match 判断案例是否满足过滤条件
+func (f CaseFilter) match(appID, label, category string) bool {
+	return (appID == "" || appID == f.AppID) && matchAny(f.Labels, label) && matchAny(f.Categories, category)
+}
+
+func matchAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, v := range allowed {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// expr 将过滤条件转换为 Milvus 布尔表达式，并与额外的表达式取交集
+func (f CaseFilter) expr(extra string) string {
+	parts := []string{vectordb.FieldAppID + " in " + quoteList(tenantScope(f.AppID))}
+	if len(f.Labels) > 0 {
+		parts = append(parts, "label in "+quoteList(f.Labels))
+	}
+	if len(f.Categories) > 0 {
+		parts = append(parts, "category in "+quoteList(f.Categories))
+	}
+	if extra = strings.TrimSpace(extra); extra != "" {
+		parts = append(parts, "("+extra+")")
+	}
+	return strings.Join(parts, " and ")
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// caseFilterOptions 是案例检索器共用的实现相关选项
+type caseFilterOptions struct {
+	filter CaseFilter
+}
+
+// WithCaseFilter 设置案例检索的元数据过滤条件
+func WithCaseFilter(f CaseFilter) retriever.Option {
+	return retriever.WrapImplSpecificOptFn(func(o *caseFilterOptions) {
+		o.filter = f
+	})
+}
+
+func caseFilterFrom(opts []retriever.Option) CaseFilter {
+	return retriever.GetImplSpecificOptions(&caseFilterOptions{}, opts...).filter
+}
+
+type sceneCategoriesKey struct{}
+
+// withSceneCategories 在上下文中记录当前场景的违规分类，供检索按类别过滤
+func withSceneCategories(ctx context.Context, taxonomy string) context.Context {
+	return context.WithValue(ctx, sceneCategoriesKey{}, splitList(taxonomy))
+}
+
+func sceneCategoriesFrom(ctx context.Context) []string {
+	categories, _ := ctx.Value(sceneCategoriesKey{}).([]string)
+	return categories
+}
+
+type tenantKey struct{}
+
+// withTenant 在上下文中记录请求所属的应用，供工具调用与检索按应用隔离
+func withTenant(ctx context.Context, appID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, appID)
+}
+
+func tenantFrom(ctx context.Context) string {
+	appID, _ := ctx.Value(tenantKey{}).(string)
+	return appID
+}
+
+// tenantScope 返回应用可见的 app_id 取值: 全局 ("") 与应用自身
+func tenantScope(appID string) []string {
+	if appID == "" {
+		return []string{""}
+	}
+	return []string{"", appID}
+}
+
+// newMilvusRetriever 连接 Milvus 并校验案例集合的字段、向量维度和索引度量
+// Milvus 不可用、集合尚未创建或无法获取 Embedding 维度时返回 nil (退化为 BM25)；定义不一致时返回错误
+func newMilvusRetriever(ctx context.Context, cfg *common.Config, emb embedding.Embedder) (*vectorRetriever, error) {
+	spec, err := vectordb.NewSpec(ctx, cfg, emb)
+	if err != nil {
+		log.Printf("警告: 无法确定案例集合定义，不使用向量检索: %v", err)
+		return nil, nil
+	}
+	cli, err := milvusclient.New(ctx, &milvusclient.ClientConfig{Address: cfg.MilvusAddr})
+	if err != nil {
+		log.Printf("警告: 连接 Milvus 失败，不使用向量检索: %v", err)
+		return nil, nil
+	}
+	if err := vectordb.CheckCollection(ctx, cli, spec); err != nil {
+		if errors.Is(err, vectordb.ErrCollectionNotFound) {
+			log.Printf("警告: %v，请先运行 init-milvus，暂不使用向量检索", err)
+			cli.Close(ctx)
+			return nil, nil
+		}
+		cli.Close(ctx)
+		return nil, fmt.Errorf("案例集合校验失败: %w", err)
+	}
+
+	r, err := milvus2.NewRetriever(ctx, &milvus2.RetrieverConfig{
+		Client:     cli,
+		Collection: spec.Collection,
+		// 不返回向量字段，减少传输量
+		OutputFields: []string{vectordb.FieldContent, vectordb.FieldLabel, vectordb.FieldCategory, vectordb.FieldCaseID, vectordb.FieldAppID},
+		TopK:         cfg.RetrieverTopK, // 默认返回的结果数 (请求可通过 WithTopK 覆盖)
+		SearchMode:   search_mode.NewApproximate(milvus2.MetricType(spec.Metric)),
+		Embedding:    emb,
+	})
+	if err != nil {
+		log.Printf("警告: 初始化 milvus retriever 失败: %v", err)
+		cli.Close(ctx)
+		return nil, nil
+	}
+	log.Printf("[EinoAgent] 向量检索已启用: 集合=%s, 维度=%d, 度量=%s", spec.Collection, spec.Dim, spec.Metric)
+	return &vectorRetriever{inner: r, filter: cfg.RetrieverFilter, metric: spec.Metric, withFilter: milvus2.WithFilter}, nil
+}
+
+// vectorRetriever 包装 Milvus 检索: 把过滤条件转换为 Milvus 表达式，并按得分阈值截断结果
+type vectorRetriever struct {
+	inner  retriever.Retriever
+	filter string            // 额外的 Milvus 过滤表达式 (RETRIEVER_FILTER)
+	metric entity.MetricType // 集合的度量，L2 的得分是距离 (越小越相似)
+	// withFilter 生成 Milvus 实现相关的过滤选项
+	withFilter func(expr string) retriever.Option
+}
+
+// Retrieve 执行向量检索，COSINE/IP 度量下返回文档的 similarity 元数据为向量相似度，
+// L2 度量下返回 distance 元数据为向量距离，此时得分阈值表示最大距离
+func (v *vectorRetriever) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	options := retriever.GetCommonOptions(&retriever.Options{}, opts...)
+	if expr := caseFilterFrom(opts).expr(v.filter); expr != "" {
+		opts = append(opts, v.withFilter(expr))
+	}
+	docs, err := v.inner.Retrieve(ctx, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	distance := v.metric == entity.L2
+	out := docs[:0]
+	for _, doc := range docs {
+		if t := options.ScoreThreshold; t != nil && (distance && doc.Score() > *t || !distance && doc.Score() < *t) {
+			continue
+		}
+		if doc.MetaData == nil {
+			doc.MetaData = make(map[string]any)
+		}
+		if distance {
+			doc.MetaData["distance"] = doc.Score()
+		} else {
+			doc.MetaData["similarity"] = doc.Score()
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+// caseSearcher 是 Agent 使用的案例检索入口，统一应用配置的 TopK、相似度阈值和过滤条件
+type caseSearcher struct {
+	inner      retriever.Retriever
+	topK       int
+	threshold  float64
+	labels     []string
+	byTaxonomy bool // 是否只检索当前场景分类体系内的案例
+}
+
+func newCaseSearcher(inner retriever.Retriever, cfg *common.Config) *caseSearcher {
+	topK := cfg.RetrieverTopK
+	if topK <= 0 {
+		topK = defaultRetrieveTopK
+	}
+	return &caseSearcher{
+		inner:      inner,
+		topK:       topK,
+		threshold:  cfg.RetrieverScoreThreshold,
+		labels:     splitList(cfg.RetrieverLabels),
+		byTaxonomy: cfg.RetrieverFilterTaxonomy,
+	}
+}
+
+// Retrieve 检索请求所属应用可见的相似案例，调用方传入的 TopK 优先于配置
+func (s *caseSearcher) Retrieve(ctx context.Context, query string, opts ...retriever.Option) ([]*schema.Document, error) {
+	filter := CaseFilter{AppID: tenantFrom(ctx), Labels: s.labels}
+	if s.byTaxonomy {
+		filter.Categories = sceneCategoriesFrom(ctx)
+	}
+	base := []retriever.Option{retriever.WithTopK(s.topK), WithCaseFilter(filter)}
+	if s.threshold > 0 {
+		base = append(base, retriever.WithScoreThreshold(s.threshold))
+	}
+	return s.inner.Retrieve(ctx, query, append(base, opts...)...)
+}
+
+// formatCases 将检索结果格式化为按得分排序的紧凑列表，供模型阅读
+func formatCases(docs []*schema.Document) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "找到 %d 个相似案例 (按相关度排序):\n", len(docs))
+	for i, doc := range docs {
+		label, _ := doc.MetaData["label"].(string)
+		category, _ := doc.MetaData["category"].(string)
+		tag := label
+		if category != "" {
+			tag += "/" + category
+		}
+		score := fmt.Sprintf("相关度 %.3f", doc.Score())
+		if similarity, ok := doc.MetaData["similarity"].(float64); ok {
+			score = fmt.Sprintf("相似度 %.3f", similarity)
+		} else if distance, ok := doc.MetaData["distance"].(float64); ok {
+			score = fmt.Sprintf("距离 %.3f", distance)
+		}
+
+		text := strings.Join(strings.Fields(doc.Content), " ")
+		if runes := []rune(text); len(runes) > maxCaseText {
+			text = string(runes[:maxCaseText]) + "..."
+		}
+		fmt.Fprintf(&sb, "%d. [%s] %s: %s\n", i+1, tag, score, text)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	"github.com/milvus-io/milvus/client/v2/entity"
+)
+
+// fixedRetriever 按顺序返回固定得分的文档
+type fixedRetriever struct {
+	scores []float64
+}
+
+func (f fixedRetriever) Retrieve(context.Context, string, ...retriever.Option) ([]*schema.Document, error) {
+	docs := make([]*schema.Document, len(f.scores))
+	for i, s := range f.scores {
+		docs[i] = (&schema.Document{Content: "case"}).WithScore(s)
+	}
+	return docs, nil
+}
+
+func TestVectorRetrieverThreshold(t *testing.T) {
+	noFilter := func(string) retriever.Option { return retriever.WithIndex("") }
+	tests := []struct {
+		name      string
+		metric    entity.MetricType
+		scores    []float64
+		threshold float64
+		want      []float64
+		key       string
+	}{
+		{"COSINE 保留相似度不低于阈值的结果", entity.COSINE, []float64{0.9, 0.7, 0.5}, 0.7, []float64{0.9, 0.7}, "similarity"},
+		{"IP 保留相似度不低于阈值的结果", entity.IP, []float64{3, 1}, 2, []float64{3}, "similarity"},
+		{"L2 保留距离不超过阈值的结果", entity.L2, []float64{0.1, 0.4, 0.8}, 0.4, []float64{0.1, 0.4}, "distance"},
+		{"未设置阈值", entity.L2, []float64{0.1, 5}, 0, []float64{0.1, 5}, "distance"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &vectorRetriever{inner: fixedRetriever{tt.scores}, metric: tt.metric, withFilter: noFilter}
+			var opts []retriever.Option
+			if tt.threshold > 0 {
+				opts = append(opts, retriever.WithScoreThreshold(tt.threshold))
+			}
+			docs, err := v.Retrieve(context.Background(), "q", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) != len(tt.want) {
+				t.Fatalf("got %d docs, want %v", len(docs), tt.want)
+			}
+			for i, doc := range docs {
+				if doc.Score() != tt.want[i] || doc.MetaData[tt.key] != tt.want[i] {
+					t.Fatalf("doc %d: score %v, metadata %v, want %v in %s", i, doc.Score(), doc.MetaData, tt.want[i], tt.key)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatCasesLabelsScore(t *testing.T) {
+	docs := []*schema.Document{
+		{Content: "a", MetaData: map[string]any{"label": "unsafe", "similarity": 0.9}},
+		{Content: "b", MetaData: map[string]any{"label": "unsafe", "distance": 0.2}},
+		(&schema.Document{Content: "c", MetaData: map[string]any{"label": "safe"}}).WithScore(0.5),
+	}
+	out := formatCases(docs)
+	for _, want := range []string{"相似度 0.900", "距离 0.200", "相关度 0.500"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("formatCases() = %q, missing %q", out, want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/milvus-io/milvus/client/v2/entity"
+	"github.com/milvus-io/milvus/client/v2/index"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+// semanticIndex 是语义缓存使用的向量索引
+// 向量均已归一化，相似度为余弦相似度
+type semanticIndex interface {
+	Search(ctx context.Context, vector []float32, scope string) (*Verdict, float64, error)
+	Add(ctx context.Context, vector []float32, scope string, v *Verdict) error
+	Purge(ctx context.Context) error
+}
+
+// SemanticCache 基于内容向量的相似结论缓存
+// 用于复用改写过措辞的重复内容 (如变体垃圾广告) 的审核结论
+type SemanticCache struct {
+	embedder  embedding.Embedder
+	threshold float64
+	index     semanticIndex
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewSemanticCache 创建语义缓存
+// embedder 为空或容量为 0 时返回 nil (所有方法对 nil 安全，相当于禁用)
+// 配置了 SEMANTIC_CACHE_COLLECTION 时使用 Milvus 集合，便于多个 Agent 实例共享
+func NewSemanticCache(ctx context.Context, cfg *common.Config, embedder embedding.Embedder) *SemanticCache {
+	if embedder == nil || cfg.SemanticCacheSize <= 0 || cfg.VerdictCacheTTL <= 0 {
+		return nil
+	}
+
+	var idx semanticIndex = newMemoryIndex(cfg.SemanticCacheSize, cfg.VerdictCacheTTL)
+	if cfg.SemanticCacheCollection != "" {
+		cli, err := milvusclient.New(ctx, &milvusclient.ClientConfig{Address: cfg.MilvusAddr})
+		if err != nil {
+			log.Printf("警告: 连接 Milvus 失败，语义缓存退回内存索引: %v", err)
+		} else {
+			idx = &milvusIndex{client: cli, collection: cfg.SemanticCacheCollection, ttl: cfg.VerdictCacheTTL}
+		}
+	}
+
+	return &SemanticCache{
+		embedder:  embedder,
+		threshold: cfg.SemanticCacheThreshold,
+		index:     idx,
+	}
+}
+
+// Lookup 查找与内容语义相近的历史结论
+// 返回的向量可在未命中时传给 Store，避免重复计算 Embedding
+func (c *SemanticCache) Lookup(ctx context.Context, content, scope string) (*Verdict, []float32, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	vectors, err := c.embedder.EmbedStrings(ctx, []string{content})
+	if err != nil {
+		log.Printf("语义缓存 embedding 失败: %v", err)
+		return nil, nil, false
+	}
+	if len(vectors) == 0 || len(vectors[0]) == 0 {
+		log.Printf("语义缓存 embedding 返回空向量")
+		return nil, nil, false
+	}
+	vector := normalizeVector(vectors[0])
+
+	v, score, err := c.index.Search(ctx, vector, scope)
+	if err != nil {
+		log.Printf("语义缓存查询失败: %v", err)
+	}
+	if v == nil || score < c.threshold {
+		c.misses.Add(1)
+		return nil, vector, false
+	}
+	c.hits.Add(1)
+	return v, vector, true
+}
+
+// Store 写入结论
+func (c *SemanticCache) Store(ctx context.Context, vector []float32, scope string, v *Verdict) {
+	if c == nil || vector == nil || v == nil {
+		return
+	}
+	if err := c.index.Add(ctx, vector, scope, v); err != nil {
+		log.Printf("语义缓存写入失败: %v", err)
+	}
+}
+
+// Purge 清空缓存 (策略变更时调用)
+func (c *SemanticCache) Purge(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	if err := c.index.Purge(ctx); err != nil {
+		log.Printf("清空语义缓存失败: %v", err)
+	}
+}
+
+// Stats 返回命中统计
+func (c *SemanticCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// CacheScope 返回语义缓存的作用域标识
+// 只有场景、语言和策略版本都相同的结论才可以复用
+func CacheScope(scene, language, policyVersion string) string {
+	return CacheKey("", scene, language, policyVersion)
+}
+
+// normalizeVector 将向量归一化为单位长度，使点积即为余弦相似度
+func normalizeVector(v64 []float64) []float32 {
+	var norm float64
+	for _, f := range v64 {
+		norm += f * f
+	}
+	norm = math.Sqrt(norm)
+	v32 := make([]float32, len(v64))
+	if norm == 0 {
+		return v32
+	}
+	for i, f := range v64 {
+		v32[i] = float32(f / norm)
+	}
+	return v32
+}
+
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// memoryIndex 是固定容量的环形内存索引，新条目覆盖最旧条目
+type memoryIndex struct {
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries []semanticEntry
+	next    int
+}
+
+type semanticEntry struct {
+	vector    []float32
+	scope     string
+	verdict   Verdict
+	expiresAt time.Time
+}
+
+func newMemoryIndex(capacity int, ttl time.Duration) *memoryIndex {
+	return &memoryIndex{ttl: ttl, entries: make([]semanticEntry, capacity)}
+}
+
+func (m *memoryIndex) Search(_ context.Context, vector []float32, scope string) (*Verdict, float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var best *semanticEntry
+	bestScore := -1.0
+	for i := range m.entries {
+		e := &m.entries[i]
+		if e.vector == nil || e.scope != scope || now.After(e.expiresAt) {
+			continue
+		}
+		if score := dot(vector, e.vector); score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil {
+		return nil, 0, nil
+	}
+	v := best.verdict
+	return &v, bestScore, nil
+}
+
+func (m *memoryIndex) Add(_ context.Context, vector []float32, scope string, v *Verdict) error {
+	m.mu.Lock()
+	m.entries[m.next] = semanticEntry{vector: vector, scope: scope, verdict: *v, expiresAt: time.Now().Add(m.ttl)}
+	m.next = (m.next + 1) % len(m.entries)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryIndex) Purge(_ context.Context) error {
+	m.mu.Lock()
+	m.entries = make([]semanticEntry, len(m.entries))
+	m.next = 0
+	m.mu.Unlock()
+	return nil
+}
+
+// maxSemanticVerdictBytes Milvus 语义缓存中结论 JSON 的最大长度 (字节)，超过的结论不写入缓存
+const maxSemanticVerdictBytes = 8192
+
+// milvusIndex 将语义缓存存放在 Milvus 集合中
+// 集合在第一次写入时按向量维度自动创建
+type milvusIndex struct {
+	client     *milvusclient.Client
+	collection string
+	ttl        time.Duration
+
+	mu     sync.Mutex
+	ready  bool
+	scopes map[string]bool // 上次清空后本实例写入过的作用域
+}
+
+func (m *milvusIndex) ensureCollection(ctx context.Context, dim int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ready {
+		return nil
+	}
+
+	has, err := m.client.HasCollection(ctx, milvusclient.NewHasCollectionOption(m.collection))
+	if err != nil {
+		return err
+	}
+	if !has {
+		schema := entity.NewSchema().WithName(m.collection).WithDescription("SafeFlow 语义结论缓存").
+			WithField(entity.NewField().WithName("id").WithDataType(entity.FieldTypeInt64).WithIsPrimaryKey(true).WithIsAutoID(true)).
+			WithField(entity.NewField().WithName("vector").WithDataType(entity.FieldTypeFloatVector).WithDim(int64(dim))).
+			WithField(entity.NewField().WithName("scope").WithDataType(entity.FieldTypeVarChar).WithMaxLength(64)).
+			WithField(entity.NewField().WithName("verdict").WithDataType(entity.FieldTypeVarChar).WithMaxLength(maxSemanticVerdictBytes)).
+			WithField(entity.NewField().WithName("expires_at").WithDataType(entity.FieldTypeInt64))
+		err = m.client.CreateCollection(ctx, milvusclient.NewCreateCollectionOption(m.collection, schema).
+			WithIndexOptions(milvusclient.NewCreateIndexOption(m.collection, "vector", index.NewAutoIndex(entity.COSINE))))
+		if err != nil {
+			return fmt.Errorf("创建语义缓存集合失败: %w", err)
+		}
+	}
+	task, err := m.client.LoadCollection(ctx, milvusclient.NewLoadCollectionOption(m.collection))
+	if err != nil {
+		return err
+	}
+	if err := task.Await(ctx); err != nil {
+		return err
+	}
+	m.ready = true
+	return nil
+}
+
+func (m *milvusIndex) Search(ctx context.Context, vector []float32, scope string) (*Verdict, float64, error) {
+	if err := m.ensureCollection(ctx, len(vector)); err != nil {
+		return nil, 0, err
+	}
+	filter := fmt.Sprintf(`scope == "%s" && expires_at > %d`, scope, time.Now().Unix())
+	results, err := m.client.Search(ctx, milvusclient.NewSearchOption(m.collection, 1, []entity.Vector{entity.FloatVector(vector)}).
+		WithANNSField("vector").
+		WithFilter(filter).
+		WithOutputFields("verdict").
+		WithSearchParam("metric_type", string(entity.COSINE)))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(results) == 0 || results[0].ResultCount == 0 {
+		return nil, 0, nil
+	}
+	raw, err := results[0].GetColumn("verdict").GetAsString(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	var v Verdict
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, 0, err
+	}
+	return &v, float64(results[0].Scores[0]), nil
+}
+
+func (m *milvusIndex) Add(ctx context.Context, vector []float32, scope string, v *Verdict) error {
+	if err := m.ensureCollection(ctx, len(vector)); err != nil {
+		return err
+	}
+	raw, _ := json.Marshal(v)
+	if len(raw) > maxSemanticVerdictBytes {
+		return fmt.Errorf("结论长度 %d 字节超过上限 %d，不写入语义缓存", len(raw), maxSemanticVerdictBytes)
+	}
+	_, err := m.client.Insert(ctx, milvusclient.NewColumnBasedInsertOption(m.collection).
+		WithFloatVectorColumn("vector", len(vector), [][]float32{vector}).
+		WithVarcharColumn("scope", []string{scope}).
+		WithVarcharColumn("verdict", []string{string(raw)}).
+		WithInt64Column("expires_at", []int64{time.Now().Add(m.ttl).Unix()}))
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	if m.scopes == nil {
+		m.scopes = make(map[string]bool)
+	}
+	m.scopes[scope] = true
+	m.mu.Unlock()
+	return nil
+}
+
+// Purge 删除本实例写入过的作用域的条目以及已过期的条目
+// 集合可能由多个 Agent 实例共用，不删除其他实例写入且仍然有效的条目
+func (m *milvusIndex) Purge(ctx context.Context) error {
+	m.mu.Lock()
+	ready, scopes := m.ready, m.scopes
+	m.scopes = nil
+	m.mu.Unlock()
+	if !ready {
+		return nil
+	}
+	_, err := m.client.Delete(ctx, milvusclient.NewDeleteOption(m.collection).WithExpr(purgeExpr(scopes, time.Now())))
+	if err != nil {
+		// 删除失败时保留作用域，下次清空时重试
+		m.mu.Lock()
+		if m.scopes == nil {
+			m.scopes = make(map[string]bool)
+		}
+		for s := range scopes {
+			m.scopes[s] = true
+		}
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// purgeExpr 返回删除指定作用域与已过期条目的 Milvus 表达式
+func purgeExpr(scopes map[string]bool, now time.Time) string {
+	expr := fmt.Sprintf("expires_at <= %d", now.Unix())
+	if len(scopes) == 0 {
+		return expr
+	}
+	list := make([]string, 0, len(scopes))
+	for s := range scopes {
+		list = append(list, s)
+	}
+	slices.Sort(list)
+	return "scope in " + quoteList(list) + " or " + expr
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+func TestPurgeExpr(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		scopes map[string]bool
+		want   string
+	}{
+		{"只清理过期条目", nil, "expires_at <= 1700000000"},
+		{"按作用域清理", map[string]bool{"b": true, "a": true}, `scope in ["a", "b"] or expires_at <= 1700000000`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := purgeExpr(tt.scopes, now); got != tt.want {
+				t.Fatalf("purgeExpr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexScope(t *testing.T) {
+	ctx := context.Background()
+	idx := newMemoryIndex(2, time.Minute)
+	vec := []float32{1, 0}
+	if err := idx.Add(ctx, vec, "s1", &Verdict{Action: "block"}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := idx.Search(ctx, vec, "s2"); v != nil {
+		t.Fatalf("Search(other scope) = %v, want nil", v)
+	}
+	v, score, _ := idx.Search(ctx, vec, "s1")
+	if v == nil || v.Action != "block" || score < 0.999 {
+		t.Fatalf("Search(s1) = %v, %v", v, score)
+	}
+	idx.Purge(ctx)
+	if v, _, _ := idx.Search(ctx, vec, "s1"); v != nil {
+		t.Fatalf("Search after Purge = %v, want nil", v)
+	}
+}
+
+// stubEmbedder 返回固定的向量或错误
+type stubEmbedder struct {
+	vectors [][]float64
+	err     error
+}
+
+func (e stubEmbedder) EmbedStrings(context.Context, []string, ...embedding.Option) ([][]float64, error) {
+	return e.vectors, e.err
+}
+
+func TestSemanticCacheLookupEmbeddingFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		emb     stubEmbedder
+		wantLog string
+	}{
+		{"embedding 出错", stubEmbedder{err: errors.New("timeout")}, "embedding 失败: timeout"},
+		{"没有返回向量", stubEmbedder{}, "embedding 返回空向量"},
+		{"返回空向量", stubEmbedder{vectors: [][]float64{{}}}, "embedding 返回空向量"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			c := &SemanticCache{embedder: tt.emb, threshold: 0.9, index: newMemoryIndex(1, time.Minute)}
+			v, vector, ok := c.Lookup(context.Background(), "hi", "s")
+			if ok || v != nil || vector != nil {
+				t.Fatalf("Lookup() = %v, %v, %v", v, vector, ok)
+			}
+			if got := buf.String(); !strings.Contains(got, tt.wantLog) || strings.Contains(got, "<nil>") {
+				t.Fatalf("log = %q, want %q", got, tt.wantLog)
+			}
+		})
+	}
+}
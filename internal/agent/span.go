@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSpans 单个结论最多保留的违规片段数
+const maxSpans = 20
+
+// Span 是模型指出的违规片段，Start/End 为在原文中的字符 (rune) 偏移，左闭右开
+// 模型只需给出 text 和 label，偏移由 LocateSpans 在原文中定位后填写
+type Span struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+	Label string `json:"label,omitempty"`
+}
+
+// LocateSpans 在原文中定位模型给出的片段并计算偏移
+// 原文中找不到的片段 (模型幻觉或改写过的引用) 会被丢弃；同一片段多次出现时全部标出
+func LocateSpans(content string, spans []Span) []Span {
+	var out []Span
+	seen := make(map[[2]int]bool)
+	for _, sp := range spans {
+		if strings.TrimSpace(sp.Text) == "" {
+			continue
+		}
+		for from := 0; from < len(content); {
+			i := strings.Index(content[from:], sp.Text)
+			if i < 0 {
+				break
+			}
+			i += from
+			start := utf8.RuneCountInString(content[:i])
+			end := start + utf8.RuneCountInString(sp.Text)
+			from = i + len(sp.Text)
+
+			key := [2]int{start, end}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, Span{Start: start, End: end, Text: sp.Text, Label: sp.Label})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Start != out[j].Start {
+			return out[i].Start < out[j].Start
+		}
+		return out[i].End < out[j].End
+	})
+	if len(out) > maxSpans {
+		out = out[:maxSpans]
+	}
+	return out
+}
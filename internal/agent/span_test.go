@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLocateSpans(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		spans   []Span
+		want    []Span
+	}{
+		{
+			name:    "按字符而不是字节计算偏移",
+			content: "你好，加微信 abc123 领奖",
+			spans:   []Span{{Text: "加微信", Label: "ad"}},
+			want:    []Span{{Start: 3, End: 6, Text: "加微信", Label: "ad"}},
+		},
+		{
+			name:    "表情等四字节字符",
+			content: "😀😀 傻瓜",
+			spans:   []Span{{Text: "傻瓜"}},
+			want:    []Span{{Start: 3, End: 5, Text: "傻瓜"}},
+		},
+		{
+			name:    "重复出现的片段全部标出",
+			content: "垃圾，真是垃圾",
+			spans:   []Span{{Text: "垃圾"}},
+			want:    []Span{{Start: 0, End: 2, Text: "垃圾"}, {Start: 5, End: 7, Text: "垃圾"}},
+		},
+		{
+			name:    "原文中找不到的片段被丢弃",
+			content: "今天天气很好",
+			spans:   []Span{{Text: "天气"}, {Text: "下雨"}, {Text: "  "}},
+			want:    []Span{{Start: 2, End: 4, Text: "天气"}},
+		},
+		{
+			name:    "重复给出的片段只保留一次并按位置排序",
+			content: "买药找我，买药",
+			spans:   []Span{{Text: "找我"}, {Text: "买药"}, {Text: "买药"}},
+			want:    []Span{{Start: 0, End: 2, Text: "买药"}, {Start: 2, End: 4, Text: "找我"}, {Start: 5, End: 7, Text: "买药"}},
+		},
+		{
+			name:    "重叠片段按起点和终点排序",
+			content: "诈骗电话",
+			spans:   []Span{{Text: "诈骗电话"}, {Text: "诈骗"}},
+			want:    []Span{{Start: 0, End: 2, Text: "诈骗"}, {Start: 0, End: 4, Text: "诈骗电话"}},
+		},
+		{
+			name:    "没有片段",
+			content: "hello",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LocateSpans(tt.content, tt.spans)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("LocateSpans() = %v, want %v", got, tt.want)
+			}
+			runes := []rune(tt.content)
+			for _, sp := range got {
+				if string(runes[sp.Start:sp.End]) != sp.Text {
+					t.Fatalf("偏移 [%d, %d) 对应 %q, want %q", sp.Start, sp.End, string(runes[sp.Start:sp.End]), sp.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestLocateSpansLimit(t *testing.T) {
+	content := strings.Repeat("坏 ", maxSpans+5)
+	if got := len(LocateSpans(content, []Span{{Text: "坏"}})); got != maxSpans {
+		t.Fatalf("len = %d, want %d", got, maxSpans)
+	}
+}
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	ucb "github.com/cloudwego/eino/utils/callbacks"
+)
+
+// maxTraceText 轨迹中单个输入/输出字段保留的最大字符数
+const maxTraceText = 2000
+
+// TraceStep 是 Agent 执行过程中的一步 (一次模型调用或一次工具调用)
+type TraceStep struct {
+	Type             string    `json:"type"` // model, tool
+	Name             string    `json:"name"` // 节点名或工具名
+	Input            string    `json:"input,omitempty"`
+	Output           string    `json:"output,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	TotalTokens      int       `json:"total_tokens,omitempty"`
+	LatencyMs        int64     `json:"latency_ms"`
+	StartedAt        time.Time `json:"started_at"`
+}
+
+// Trace 收集一次请求的执行轨迹，可被并发的片段/集成成员同时写入
+type Trace struct {
+	mu    sync.Mutex
+	steps []TraceStep
+}
+
+// Steps 返回已记录的步骤 (按完成顺序)
+func (t *Trace) Steps() []TraceStep {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceStep(nil), t.steps...)
+}
+
+func (t *Trace) add(step TraceStep) {
+	t.mu.Lock()
+	t.steps = append(t.steps, step)
+	t.mu.Unlock()
+}
+
+type (
+	traceKey      struct{}
+	stepStartKey  struct{}
+	modelInputKey struct{}
+	toolInputKey  struct{}
+)
+
+// withTrace 在上下文中挂载轨迹收集器
+func withTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+func traceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// newTraceHandler 创建记录模型与工具调用的 Eino 回调
+// 回调只在上下文中挂载了轨迹收集器时记录，OnStart 把开始时间放进上下文供 OnEnd 计算耗时
+func newTraceHandler() callbacks.Handler {
+	onStart := func(ctx context.Context) context.Context {
+		if traceFrom(ctx) == nil {
+			return ctx
+		}
+		return context.WithValue(ctx, stepStartKey{}, time.Now())
+	}
+	finish := func(ctx context.Context, step TraceStep) context.Context {
+		t := traceFrom(ctx)
+		if t == nil {
+			return ctx
+		}
+		if start, ok := ctx.Value(stepStartKey{}).(time.Time); ok {
+			step.StartedAt = start
+			step.LatencyMs = time.Since(start).Milliseconds()
+		}
+		t.add(step)
+		return ctx
+	}
+
+	modelHandler := &ucb.ModelCallbackHandler{
+		OnStart: func(ctx context.Context, info *callbacks.RunInfo, input *model.CallbackInput) context.Context {
+			ctx = onStart(ctx)
+			if len(input.Messages) > 0 {
+				// 记录本轮送入模型的最后一条消息 (首轮为待审核内容，之后为工具结果)
+				ctx = context.WithValue(ctx, modelInputKey{}, formatMessage(input.Messages[len(input.Messages)-1]))
+			}
+			return ctx
+		},
+		OnEnd: func(ctx context.Context, info *callbacks.RunInfo, output *model.CallbackOutput) context.Context {
+			step := TraceStep{Type: "model", Name: stepName(info)}
+			step.Input, _ = ctx.Value(modelInputKey{}).(string)
+			if output.Message != nil {
+				step.Output = formatMessage(output.Message)
+			}
+			if u := output.TokenUsage; u != nil {
+				step.PromptTokens, step.CompletionTokens, step.TotalTokens = u.PromptTokens, u.CompletionTokens, u.TotalTokens
+			}
+			return finish(ctx, step)
+		},
+		OnError: func(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+			step := TraceStep{Type: "model", Name: stepName(info), Error: err.Error()}
+			step.Input, _ = ctx.Value(modelInputKey{}).(string)
+			return finish(ctx, step)
+		},
+	}
+
+	toolHandler := &ucb.ToolCallbackHandler{
+		OnStart: func(ctx context.Context, info *callbacks.RunInfo, input *tool.CallbackInput) context.Context {
+			ctx = onStart(ctx)
+			return context.WithValue(ctx, toolInputKey{}, input.ArgumentsInJSON)
+		},
+		OnEnd: func(ctx context.Context, info *callbacks.RunInfo, output *tool.CallbackOutput) context.Context {
+			args, _ := ctx.Value(toolInputKey{}).(string)
+			return finish(ctx, TraceStep{Type: "tool", Name: stepName(info), Input: truncateText(args), Output: truncateText(output.Response)})
+		},
+		OnError: func(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+			args, _ := ctx.Value(toolInputKey{}).(string)
+			return finish(ctx, TraceStep{Type: "tool", Name: stepName(info), Input: truncateText(args), Error: err.Error()})
+		},
+	}
+
+	return ucb.NewHandlerHelper().ChatModel(modelHandler).Tool(toolHandler).Handler()
+}
+
+// stepName 返回步骤名: 工具名或图节点名，未命名时使用组件类型
+func stepName(info *callbacks.RunInfo) string {
+	if info.Name != "" {
+		return info.Name
+	}
+	return info.Type
+}
+
+// formatMessage 将消息格式化为轨迹文本，包含工具调用请求
+func formatMessage(msg *schema.Message) string {
+	text := string(msg.Role) + ": " + msg.Content
+	if len(msg.ToolCalls) > 0 {
+		calls, _ := json.Marshal(msg.ToolCalls)
+		text += " tool_calls=" + string(calls)
+	}
+	return truncateText(text)
+}
+
+func truncateText(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxTraceText {
+		return s
+	}
+	return string(runes[:maxTraceText]) + "..."
+}
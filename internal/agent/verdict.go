@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Request 描述一次 Agent 审核调用
+type Request struct {
+	RequestID string
+	AppID     string // 调用方应用，用于选择该应用的提示词、实体词典和案例，为空时只使用全局策略
+	Content   string
+	Scene     string // 业务场景，用于选择提示词模板
+	Language  string // 内容语言
+	Mode      string // 审核模式 (content / prompt_injection / output_guard)，为空时按 content 处理
+
+	// 对话上下文 (output_guard 模式)，TargetIndex 为待审核消息的下标
+	Conversation []Turn
+	TargetIndex  int
+}
+
+// Verdict 是 Agent 给出的审核结论
+type Verdict struct {
+	Action        string          `json:"action"`
+	Reason        string          `json:"reason"`
+	Category      string          `json:"category,omitempty"`       // 违规类别
+	Confidence    float64         `json:"confidence,omitempty"`     // 模型给出的置信度 (0~1)
+	Rewrite       string          `json:"rewrite,omitempty"`        // action 为 rewrite 时的安全替换文本
+	Spans         []Span          `json:"spans,omitempty"`          // 违规片段 (已在原文中定位)
+	Members       []MemberVerdict `json:"members,omitempty"`        // 集成模式下各成员的结论 (由 Agent 填写，非模型输出)
+	PromptVersion string          `json:"prompt_version,omitempty"` // 所用提示词模板版本 (由 Agent 填写，非模型输出)
+	Tier          string          `json:"tier,omitempty"`           // 给出结论的层级: fast, full (由 Agent 填写)
+	Trace         []TraceStep     `json:"-"`                        // 本次执行的模型与工具调用轨迹 (不缓存)
+	Degraded      bool            `json:"-"`                        // 结果无法解析等降级情况，不应被缓存
+}
+
+// parseVerdict 解析模型返回的 JSON 结论
+// 解析失败时返回 review，并在理由中附带原始输出
+func parseVerdict(output string) *Verdict {
+	var v Verdict
+	if err := json.Unmarshal([]byte(cleanJSON(output)), &v); err != nil {
+		return &Verdict{Action: "review", Reason: "解析结果失败。原始输出: " + output, Degraded: true}
+	}
+	// 要求改写却没有给出替换文本时，无法安全放行，按拦截处理
+	if v.Action == "rewrite" && strings.TrimSpace(v.Rewrite) == "" {
+		v.Action = "block"
+		v.Reason += " (未给出改写文本，按拦截处理)"
+	}
+	return &v
+}
+
+// cleanJSON 清理 JSON 字符串中的 Markdown 标记
+func cleanJSON(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
+}
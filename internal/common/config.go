@@ -3,6 +3,7 @@ package common
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -23,6 +24,63 @@ type Config struct {
 	ArkModelID        string `mapstructure:"ARK_MODEL_ID"`
 	ArkEmbeddingModel string `mapstructure:"ARK_EMBEDDING_MODEL"`
 	MilvusAddr        string `mapstructure:"MILVUS_ADDR"`
+
+	VerdictCacheSize int           `mapstructure:"VERDICT_CACHE_SIZE"` // 结论缓存容量 (0 表示禁用)
+	VerdictCacheTTL  time.Duration `mapstructure:"VERDICT_CACHE_TTL"`  // 结论缓存有效期
+
+	SemanticCacheSize       int     `mapstructure:"SEMANTIC_CACHE_SIZE"`       // 语义缓存内存索引容量 (0 表示禁用)
+	SemanticCacheThreshold  float64 `mapstructure:"SEMANTIC_CACHE_THRESHOLD"`  // 命中所需的最小余弦相似度
+	SemanticCacheCollection string  `mapstructure:"SEMANTIC_CACHE_COLLECTION"` // 可选的 Milvus 集合名，为空时仅使用内存索引
+
+	ChunkSize    int `mapstructure:"CHUNK_SIZE"`    // 长文本切分阈值 (字符数，0 表示不切分)
+	ChunkOverlap int `mapstructure:"CHUNK_OVERLAP"` // 相邻片段的重叠字符数
+	ChunkWorkers int `mapstructure:"CHUNK_WORKERS"` // 单个请求并发审核片段的最大数量
+
+	EnsembleModels   string `mapstructure:"ENSEMBLE_MODELS"`   // 集成成员，逗号分隔的 模型ID[@场景]，为空时只使用 ARK_MODEL_ID
+	EnsembleStrategy string `mapstructure:"ENSEMBLE_STRATEGY"` // 集成策略: majority, unanimous, any_block, escalate
+
+	CascadeModelID            string  `mapstructure:"CASCADE_MODEL_ID"`            // 快速初审小模型，为空时不启用级联路由
+	CascadeThreshold          float64 `mapstructure:"CASCADE_THRESHOLD"`           // 初审结论直接采用所需的最小置信度
+	CascadeEscalateCategories string  `mapstructure:"CASCADE_ESCALATE_CATEGORIES"` // 总是升级到完整 Agent 的高风险类别，逗号分隔
+
+	RetrieverMode           string  `mapstructure:"RETRIEVER_MODE"`            // 案例检索方式: hybrid (向量 + BM25 融合), vector, bm25
+	RetrieverCollection     string  `mapstructure:"RETRIEVER_COLLECTION"`      // 案例向量集合名
+	RetrieverTopK           int     `mapstructure:"RETRIEVER_TOP_K"`           // 每次检索返回的案例数
+	RetrieverScoreThreshold float64 `mapstructure:"RETRIEVER_SCORE_THRESHOLD"` // 向量检索的最低相似度，L2 度量下为最大距离 (0 表示不限)
+	RetrieverLabels         string  `mapstructure:"RETRIEVER_LABELS"`          // 只检索这些标签的案例，逗号分隔，为空表示不限
+	RetrieverFilterTaxonomy bool    `mapstructure:"RETRIEVER_FILTER_TAXONOMY"` // 是否只检索当前场景分类体系内的案例
+	RetrieverFilter         string  `mapstructure:"RETRIEVER_FILTER"`          // 额外的 Milvus 过滤表达式，只作用于向量检索
+	EmbeddingDim            int     `mapstructure:"EMBEDDING_DIM"`             // 向量维度，0 表示启动时从 Embedding 模型获取
+	MilvusMetric            string  `mapstructure:"MILVUS_METRIC"`             // 案例集合的相似度度量: COSINE, IP, L2
+	MilvusIndexType         string  `mapstructure:"MILVUS_INDEX_TYPE"`         // 案例集合的向量索引类型: AUTOINDEX, IVF_FLAT, HNSW, FLAT
+
+	CaseReconcileInterval     time.Duration `mapstructure:"CASE_RECONCILE_INTERVAL"`      // 案例库与 Milvus 对账的间隔 (0 表示不启用)
+	CaseAutoPromote           bool          `mapstructure:"CASE_AUTO_PROMOTE"`            // 复核人确认拦截时是否自动沉淀为案例
+	CaseAutoPromoteConfidence float64       `mapstructure:"CASE_AUTO_PROMOTE_CONFIDENCE"` // 自动沉淀要求的最低模型置信度
+	CaseDuplicateThreshold    float64       `mapstructure:"CASE_DUPLICATE_THRESHOLD"`     // 去重任务判定近似重复的最低向量相似度 (0 表示只检查内容完全相同的案例)
+	CaseDedupeInterval        time.Duration `mapstructure:"CASE_DEDUPE_INTERVAL"`         // 案例去重任务的执行间隔 (0 表示不启用)
+
+	APIKeyRequired   bool   `mapstructure:"API_KEY_REQUIRED"`   // 提交审核是否必须携带有效的 API Key (X-API-Key)
+	CORSAllowOrigins string `mapstructure:"CORS_ALLOW_ORIGINS"` // 允许跨域访问的来源，逗号分隔，* 表示任意来源
+
+	RateLimitKeyRPS    float64 `mapstructure:"RATE_LIMIT_KEY_RPS"`    // 每个 API Key 每秒允许提交的内容条数 (0 表示不限)
+	RateLimitKeyBurst  int     `mapstructure:"RATE_LIMIT_KEY_BURST"`  // 每个 API Key 允许的突发条数
+	RateLimitUserRPS   float64 `mapstructure:"RATE_LIMIT_USER_RPS"`   // 每个 user_id 每秒允许提交的内容条数 (0 表示不限)
+	RateLimitUserBurst int     `mapstructure:"RATE_LIMIT_USER_BURST"` // 每个 user_id 允许的突发条数
+	LLMDailyQuota      int     `mapstructure:"LLM_DAILY_QUOTA"`       // 每个应用每天默认的 LLM 调用配额 (0 表示不限)，应用可单独设置
+
+	AsyncWorkers int `mapstructure:"ASYNC_WORKERS"` // 每个网关实例处理异步提交的工作协程数
+
+	AdminTokenSecret string        `mapstructure:"ADMIN_TOKEN_SECRET"` // 签发管理后台登录令牌的 HMAC 密钥，为空时启动时随机生成 (重启后需重新登录)
+	AdminTokenTTL    time.Duration `mapstructure:"ADMIN_TOKEN_TTL"`    // 登录令牌有效期
+	AdminUsername    string        `mapstructure:"ADMIN_USERNAME"`     // 还没有管理员账号时创建的初始管理员用户名
+	AdminPassword    string        `mapstructure:"ADMIN_PASSWORD"`     // 初始管理员密码，为空时随机生成并打印到日志
+
+	TracePublish bool `mapstructure:"TRACE_PUBLISH"` // 是否把 Agent 执行轨迹发布到 NATS 供审计服务保存
+
+	ConversationHistory int           `mapstructure:"CONVERSATION_HISTORY"` // 审核 IM 消息时附带的同会话历史消息条数 (0 表示不附带)
+	ConversationMax     int           `mapstructure:"CONVERSATION_MAX"`     // 会话存储最多保留的会话数
+	ConversationTTL     time.Duration `mapstructure:"CONVERSATION_TTL"`     // 会话无新消息后保留的时长
 }
 
 // LoadConfig 从环境变量加载配置
@@ -42,6 +100,48 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("ARK_API_KEY", "")
 	viper.SetDefault("ARK_MODEL_ID", "")
 	viper.SetDefault("ARK_EMBEDDING_MODEL", "")
+	viper.SetDefault("VERDICT_CACHE_SIZE", 10000)
+	viper.SetDefault("VERDICT_CACHE_TTL", "10m")
+	viper.SetDefault("SEMANTIC_CACHE_SIZE", 2000)
+	viper.SetDefault("SEMANTIC_CACHE_THRESHOLD", 0.95)
+	viper.SetDefault("SEMANTIC_CACHE_COLLECTION", "")
+	viper.SetDefault("CHUNK_SIZE", 2000)
+	viper.SetDefault("CHUNK_OVERLAP", 200)
+	viper.SetDefault("CHUNK_WORKERS", 4)
+	viper.SetDefault("ENSEMBLE_MODELS", "")
+	viper.SetDefault("ENSEMBLE_STRATEGY", "majority")
+	viper.SetDefault("CASCADE_MODEL_ID", "")
+	viper.SetDefault("CASCADE_THRESHOLD", 0.85)
+	viper.SetDefault("CASCADE_ESCALATE_CATEGORIES", "政治敏感,暴力恐怖")
+	viper.SetDefault("RETRIEVER_MODE", "hybrid")
+	viper.SetDefault("RETRIEVER_COLLECTION", "sensitive_cases")
+	viper.SetDefault("RETRIEVER_TOP_K", 3)
+	viper.SetDefault("RETRIEVER_SCORE_THRESHOLD", 0.0)
+	viper.SetDefault("RETRIEVER_LABELS", "")
+	viper.SetDefault("RETRIEVER_FILTER_TAXONOMY", false)
+	viper.SetDefault("RETRIEVER_FILTER", "")
+	viper.SetDefault("EMBEDDING_DIM", 0)
+	viper.SetDefault("MILVUS_METRIC", "COSINE")
+	viper.SetDefault("MILVUS_INDEX_TYPE", "AUTOINDEX")
+	viper.SetDefault("CASE_RECONCILE_INTERVAL", "10m")
+	viper.SetDefault("CASE_AUTO_PROMOTE", false)
+	viper.SetDefault("CASE_AUTO_PROMOTE_CONFIDENCE", 0.9)
+	viper.SetDefault("CASE_DUPLICATE_THRESHOLD", 0.95)
+	viper.SetDefault("CASE_DEDUPE_INTERVAL", "24h")
+	viper.SetDefault("API_KEY_REQUIRED", true)
+	viper.SetDefault("CORS_ALLOW_ORIGINS", "*")
+	viper.SetDefault("RATE_LIMIT_KEY_RPS", 20)
+	viper.SetDefault("RATE_LIMIT_KEY_BURST", 40)
+	viper.SetDefault("RATE_LIMIT_USER_RPS", 2)
+	viper.SetDefault("RATE_LIMIT_USER_BURST", 10)
+	viper.SetDefault("LLM_DAILY_QUOTA", 0)
+	viper.SetDefault("ASYNC_WORKERS", 4)
+	viper.SetDefault("ADMIN_TOKEN_TTL", "12h")
+	viper.SetDefault("ADMIN_USERNAME", "admin")
+	viper.SetDefault("TRACE_PUBLISH", true)
+	viper.SetDefault("CONVERSATION_HISTORY", 5)
+	viper.SetDefault("CONVERSATION_MAX", 10000)
+	viper.SetDefault("CONVERSATION_TTL", "30m")
 
 	configFile := os.Getenv("CONFIG_FILE")
 	if configFile != "" {
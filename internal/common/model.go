@@ -1,25 +1,62 @@
 package common
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // ContentSubmittedEvent 是用户提交内容后发布的事件
 // 主题: content.submitted
+// 异步提交 (/submit/async) 发布该事件，由网关的审核工作协程消费
 type ContentSubmittedEvent struct {
-	RequestID string    `json:"request_id"` // 请求唯一 ID
-	UserID    string    `json:"user_id"`    // 用户 ID
-	Content   string    `json:"content"`    // 提交的内容
-	Timestamp time.Time `json:"timestamp"`  // 事件发生时间
+	RequestID    string    `json:"request_id"`              // 请求唯一 ID
+	UserID       string    `json:"user_id"`                 // 用户 ID
+	Content      string    `json:"content"`                 // 提交的内容
+	AppID        string    `json:"app_id,omitempty"`        // 调用方应用 ID
+	Scene        string    `json:"scene,omitempty"`         // 业务场景
+	Language     string    `json:"language,omitempty"`      // 内容语言
+	Mode         string    `json:"mode,omitempty"`          // 审核模式
+	IncludeTrace bool      `json:"include_trace,omitempty"` // 是否在结果中保留 Agent 执行轨迹
+	Timestamp    time.Time `json:"timestamp"`               // 事件发生时间
 }
 
 // ContentResultEvent 是审核完成后的结果事件
 // 主题: content.result
 // 用于通知审计服务或其他下游服务
 type ContentResultEvent struct {
-	RequestID string    `json:"request_id"`
-	UserID    string    `json:"user_id"`
-	Action    string    `json:"action"` // 动作: allow(通过), block(拦截), review(需复核)
-	Reason    string    `json:"reason"` // 审核理由
-	Source    string    `json:"source"` // 决策来源: rule-engine(规则引擎), llm-agent(大模型)
+	RequestID      string    `json:"request_id"`
+	UserID         string    `json:"user_id"`
+	Action         string    `json:"action"`                    // 动作: allow(通过), block(拦截), review(需复核)
+	Reason         string    `json:"reason"`                    // 审核理由
+	Source         string    `json:"source"`                    // 决策来源: rule-engine(规则引擎), llm-agent(大模型)
+	AppID          string    `json:"app_id,omitempty"`          // 调用方应用 ID
+	ConversationID string    `json:"conversation_id,omitempty"` // IM 会话 ID (可选)
+	Content        string    `json:"content"`                   // 被审核的内容 (复核后可沉淀为案例)
+	Category       string    `json:"category,omitempty"`        // 违规类别
+	Confidence     float64   `json:"confidence,omitempty"`      // 模型给出的置信度 (0~1)
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// AgentTraceEvent 是 LLM Agent 一次审核的执行轨迹
+// 主题: agent.trace
+// 由审计服务持久化，用于排查 Agent 调用了哪些工具、每一步的耗时与 Token 用量
+type AgentTraceEvent struct {
+	RequestID   string          `json:"request_id"`
+	AppID       string          `json:"app_id,omitempty"`
+	Steps       json.RawMessage `json:"steps"` // 步骤列表 (模型调用、工具调用)
+	TotalTokens int             `json:"total_tokens"`
+	LatencyMs   int64           `json:"latency_ms"` // 从收到请求到得出结论的实际耗时
+	Timestamp   time.Time       `json:"timestamp"`
+}
+
+// PolicyChangedEvent 是策略 (规则、案例、提示词等) 变更后发布的事件
+// 主题: policy.changed
+// 下游服务收到后应刷新本地策略并清理依赖旧策略的缓存
+type PolicyChangedEvent struct {
+	Type      string    `json:"type"`   // 变更对象类型: rule, case, prompt, snapshot
+	Target    string    `json:"target"` // 变更对象标识 (如 prompt:3)
 	Timestamp time.Time `json:"timestamp"`
 }
 
@@ -28,25 +65,156 @@ const (
 	SubjectContentSubmitted = "content.submitted"
 	// SubjectContentResult 审核结果事件主题
 	SubjectContentResult = "content.result"
+	// SubjectPolicyChanged 策略变更事件主题 (不进入 JetStream，仅广播)
+	SubjectPolicyChanged = "policy.changed"
+	// SubjectAgentTrace Agent 执行轨迹主题 (不进入 JetStream)
+	SubjectAgentTrace = "agent.trace"
 
 	// StreamName NATS JetStream 流名称
 	StreamName = "SAFEFLOW"
 )
 
+// 审核模式 (ScanRequest.mode)
+const (
+	// ScanModeContent 通用内容安全审核 (默认)
+	ScanModeContent = "content"
+	// ScanModePromptInjection 大模型输入护栏: 检测提示词注入、越狱、系统提示词窃取和数据外泄指令
+	ScanModePromptInjection = "prompt_injection"
+	// ScanModeOutputGuard 大模型输出护栏: 结合对话上下文审核助手回复，可给出改写后的安全文本
+	ScanModeOutputGuard = "output_guard"
+)
+
+// AgentTrace 定义 Agent 执行轨迹的数据库模型
+type AgentTrace struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	RequestID   string    `gorm:"type:varchar(100);index" json:"request_id"`
+	AppID       string    `gorm:"type:varchar(64);index" json:"app_id"`
+	Steps       string    `gorm:"type:mediumtext" json:"steps"` // 步骤列表 (JSON)
+	TotalTokens int       `json:"total_tokens"`
+	LatencyMs   int64     `json:"latency_ms"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 // AuditLog 定义审计日志的数据库模型
+// Review* 字段由人工复核填写，复核结论可沉淀为案例库案例 (CaseID)
 type AuditLog struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`    // 自增主键
-	RequestID string    `gorm:"index" json:"request_id"` // 请求 ID (建立索引以加速查询)
-	UserID    string    `json:"user_id"`                 // 用户 ID
-	Action    string    `json:"action"`                  // 动作 (allow, block, review)
-	Reason    string    `json:"reason"`                  // 原因
-	Source    string    `json:"source"`                  // 来源 (rule-engine, llm-agent)
-	CreatedAt time.Time `json:"created_at"`              // 创建时间
+	ID             uint       `gorm:"primaryKey" json:"id"`                           // 自增主键
+	RequestID      string     `gorm:"index" json:"request_id"`                        // 请求 ID (建立索引以加速查询)
+	UserID         string     `json:"user_id"`                                        // 用户 ID
+	Action         string     `json:"action"`                                         // 动作 (allow, block, review)
+	Reason         string     `json:"reason"`                                         // 原因
+	Source         string     `json:"source"`                                         // 来源 (rule-engine, llm-agent)
+	AppID          string     `gorm:"type:varchar(64);index" json:"app_id"`           // 调用方应用 ID
+	ConversationID string     `gorm:"type:varchar(100);index" json:"conversation_id"` // IM 会话 ID，便于按会话回溯审核记录
+	Content        string     `gorm:"type:text" json:"content"`                       // 被审核的内容
+	Category       string     `gorm:"type:varchar(50)" json:"category"`               // 违规类别
+	Confidence     float64    `json:"confidence"`                                     // 模型给出的置信度
+	ReviewLabel    string     `gorm:"type:varchar(20)" json:"review_label"`           // 复核结论标签 (safe, unsafe)，为空表示未复核
+	ReviewCategory string     `gorm:"type:varchar(50)" json:"review_category"`        // 复核确认的违规类别
+	Reviewer       string     `gorm:"type:varchar(100)" json:"reviewer"`              // 复核人
+	ReviewedAt     *time.Time `json:"reviewed_at"`                                    // 复核时间
+	CaseID         uint       `gorm:"index" json:"case_id"`                           // 沉淀出的案例 ID (0 表示未沉淀)
+	CreatedAt      time.Time  `json:"created_at"`                                     // 创建时间
+}
+
+// Application 定义接入审核服务的应用 (调用方身份)
+type Application struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	AppID       string `gorm:"type:varchar(64);uniqueIndex;not null" json:"app_id"` // 应用标识，写入审核请求与审计日志
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+	IsEnabled   bool   `gorm:"default:true" json:"is_enabled"` // 停用后该应用的所有 API Key 失效
+	// DailyLLMQuota 每日 LLM 调用配额，0 表示使用默认配额 (LLM_DAILY_QUOTA)，负数表示不限
+	DailyLLMQuota int       `json:"daily_llm_quota"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// 异步审核任务状态
+const (
+	ScanStatusPending = "pending" // 已提交，等待审核
+	ScanStatusDone    = "done"    // 审核完成
+	ScanStatusFailed  = "failed"  // 审核失败 (规则引擎多次调用失败、配额用完等)
+)
+
+// ScanResult 异步提交的审核任务及其结果，供调用方轮询 (GET /results/:request_id)
+type ScanResult struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	RequestID   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"request_id"`
+	AppID       string     `gorm:"type:varchar(64);index" json:"app_id"`
+	UserID      string     `gorm:"type:varchar(64)" json:"user_id"`
+	Status      string     `gorm:"type:varchar(20);index" json:"status"` // pending, done, failed
+	Action      string     `gorm:"type:varchar(20)" json:"action"`       // 审核结论，完成后写入
+	Response    string     `gorm:"type:mediumtext" json:"-"`             // 完整的审核结果 (ScanResponse JSON)
+	Error       string     `gorm:"type:varchar(512)" json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// QuotaUsage 记录应用每天的 LLM 调用次数 (请求转发给 LLM Agent 的次数)
+type QuotaUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AppID     string    `gorm:"type:varchar(64);uniqueIndex:idx_quota_app_day;not null" json:"app_id"`
+	Day       string    `gorm:"type:char(10);uniqueIndex:idx_quota_app_day;not null" json:"day"` // 日期 (2006-01-02，网关本地时区)
+	LLMCalls  int64     `json:"llm_calls"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// APIKey 定义应用的 API Key，只保存哈希，明文仅在创建或轮换时返回一次
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	AppID      string     `gorm:"type:varchar(64);index;not null" json:"app_id"`
+	Name       string     `gorm:"type:varchar(100)" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16)" json:"prefix"`              // 明文前缀，便于辨认是哪个 Key
+	KeyHash    string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"` // SHA-256 (十六进制)
+	ExpiresAt  *time.Time `json:"expires_at"`                                  // 过期时间 (轮换时旧 Key 的宽限期)，为空表示不过期
+	RevokedAt  *time.Time `json:"revoked_at"`                                  // 吊销时间，为空表示有效
+	LastUsedAt *time.Time `json:"last_used_at"`                                // 最近一次使用时间 (按分钟更新)
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// 管理后台角色
+const (
+	RoleAdmin        = "admin"         // 全部权限
+	RolePolicyEditor = "policy_editor" // 维护规则、案例、词典与提示词
+	RoleReviewer     = "reviewer"      // 复核审核结果
+	RoleAuditor      = "auditor"       // 只读查看审计日志
+)
+
+// AdminUser 定义管理后台账号
+type AdminUser struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Username     string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"username"`
+	PasswordHash string     `gorm:"type:varchar(255);not null" json:"-"`   // PBKDF2-SHA256 哈希
+	Role         string     `gorm:"type:varchar(20);not null" json:"role"` // admin, policy_editor, reviewer, auditor
+	AppID        string     `gorm:"type:varchar(64)" json:"app_id"`        // 限定只能管理该应用，为空表示可管理所有应用与全局策略
+	IsEnabled    bool       `gorm:"default:true" json:"is_enabled"`        // 停用后已签发的令牌立即失效
+	TokenVersion uint       `json:"-"`                                     // 令牌版本，修改密码或停用时递增，使已签发的令牌失效
+	LastLoginAt  *time.Time `json:"last_login_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// OperationLog 记录一次管理后台的修改操作
+type OperationLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Actor     string    `gorm:"type:varchar(64);index" json:"actor"` // 操作人用户名
+	Role      string    `gorm:"type:varchar(20)" json:"role"`
+	AppID     string    `gorm:"type:varchar(64);index" json:"app_id"` // 操作所针对的应用，为空表示全局
+	Method    string    `gorm:"type:varchar(10)" json:"method"`
+	Route     string    `gorm:"type:varchar(100);index" json:"route"` // 路由模板 (如 /admin/rules/:id)
+	Path      string    `gorm:"type:varchar(255)" json:"path"`        // 实际请求路径
+	Status    int       `json:"status"`                               // 响应状态码
+	Before    string    `gorm:"type:mediumtext" json:"before"`        // 修改前的对象 (JSON)，新建时为空
+	After     string    `gorm:"type:mediumtext" json:"after"`         // 修改后的对象 (JSON)，删除时为空
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 }
 
 // Rule 定义规则引擎的规则
 type Rule struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
+	AppID       string    `gorm:"type:varchar(64);index" json:"app_id"`      // 所属应用，为空表示对所有应用生效的全局规则
 	Pattern     string    `gorm:"type:varchar(255);not null" json:"pattern"` // 关键词或正则表达式
 	Type        string    `gorm:"type:varchar(20);not null" json:"type"`     // "keyword", "regex"
 	Action      string    `gorm:"type:varchar(20);not null" json:"action"`   // "block", "allow"
@@ -60,18 +228,46 @@ type Rule struct {
 
 // Case 定义知识库案例 (RAG 源)
 type Case struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Content   string    `gorm:"type:text" json:"content"`
-	Label     string    `gorm:"type:varchar(20)" json:"label"` // "safe", "unsafe"
-	Category  string    `gorm:"type:varchar(50)" json:"category"`
-	VectorID  int64     `json:"vector_id"`                      // Milvus 中的 ID
-	IsCustom  bool      `gorm:"default:false" json:"is_custom"` // 是否为用户上传的自定义案例
-	CreatedAt time.Time `json:"created_at"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	AppID           string         `gorm:"type:varchar(64);index" json:"app_id"` // 所属应用，为空表示所有应用共享的全局案例
+	Content         string         `gorm:"type:text" json:"content"`
+	Label           string         `gorm:"type:varchar(20)" json:"label"` // "safe", "unsafe"
+	Category        string         `gorm:"type:varchar(50)" json:"category"`
+	VectorID        int64          `json:"vector_id"`                                        // Milvus 中的 ID (0 表示尚未同步)
+	IsCustom        bool           `gorm:"default:false" json:"is_custom"`                   // 是否为用户上传的自定义案例
+	SourceRequestID string         `gorm:"type:varchar(100);index" json:"source_request_id"` // 由审计记录沉淀时对应的审核请求 ID，用于溯源
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"` // 软删除，对应向量由对账任务确认清理
+}
+
+// 案例质量问题的类型与状态
+const (
+	CaseIssueDuplicate     = "duplicate"      // 内容相同、标签一致
+	CaseIssueNearDuplicate = "near_duplicate" // 内容高度相似、标签一致
+	CaseIssueContradiction = "contradiction"  // 内容相同或高度相似但标签不同
+
+	CaseIssueOpen    = "open"    // 待处理
+	CaseIssueIgnored = "ignored" // 已确认无需处理，去重任务不再标记
+)
+
+// CaseIssue 记录去重任务发现的一对存在问题的案例，供人工清理
+type CaseIssue struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	AppID       string    `gorm:"type:varchar(64);index" json:"app_id"` // 问题案例所属应用 (只比较同一应用内的案例)
+	CaseID      uint      `gorm:"index" json:"case_id"`                 // 问题案例对中 ID 较小的一方
+	OtherCaseID uint      `gorm:"index" json:"other_case_id"`           // 问题案例对中 ID 较大的一方
+	Type        string    `gorm:"type:varchar(20);index" json:"type"`   // duplicate, near_duplicate, contradiction
+	Score       float32   `json:"score"`                                // 向量相似度 (内容完全相同时为 1)
+	Status      string    `gorm:"type:varchar(20);index" json:"status"` // open, ignored
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // AuditTask 定义批量审核任务
 type AuditTask struct {
 	ID        string    `gorm:"primaryKey" json:"id"` // UUID
+	AppID     string    `gorm:"type:varchar(64);index" json:"app_id"`
 	UserID    string    `gorm:"index" json:"user_id"`
 	Status    string    `gorm:"type:varchar(20)" json:"status"` // "pending", "processing", "completed", "failed"
 	Total     int       `json:"total"`
@@ -84,9 +280,43 @@ type AuditTask struct {
 // PolicyVersion 定义策略版本
 type PolicyVersion struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	Version   string    `gorm:"type:varchar(50)" json:"version"` // 版本号 (如 v1.0.1)
-	Type      string    `gorm:"type:varchar(20)" json:"type"`    // "rule", "model"
-	Config    string    `gorm:"type:text" json:"config"`         // 配置快照 (JSON)
+	AppID     string    `gorm:"type:varchar(64);index" json:"app_id"`  // 所属应用，为空表示全局策略
+	Version   string    `gorm:"type:varchar(50)" json:"version"`       // 版本号 (如 v1.0.1)
+	Type      string    `gorm:"type:varchar(20)" json:"type"`          // "rule", "model"
+	Target    string    `gorm:"type:varchar(100);index" json:"target"` // 策略对象 (如 prompt:3)，整体快照为空
+	Config    string    `gorm:"type:text" json:"config"`               // 配置快照 (JSON)
+	Comment   string    `gorm:"type:varchar(255)" json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptTemplate 定义大模型系统提示词模板
+// Content 使用 Go text/template 语法，可引用 {{.Scene}}、{{.Language}}、{{.Taxonomy}} 变量
+type PromptTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AppID     string    `gorm:"type:varchar(64);uniqueIndex:idx_prompt_app_scene_version" json:"app_id"` // 所属应用，为空表示全局模板 (应用未配置该场景时使用)
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Scene     string    `gorm:"type:varchar(50);uniqueIndex:idx_prompt_app_scene_version" json:"scene"`   // 适用场景 (如 "default", "im", "comment")
+	Content   string    `gorm:"type:text;not null" json:"content"`                                        // 模板内容
+	Taxonomy  string    `gorm:"type:varchar(255)" json:"taxonomy"`                                        // 分类体系 (逗号分隔)，为空时使用内置分类
+	FewShotK  int       `gorm:"default:0" json:"few_shot_k"`                                              // 调用模型前注入的相似案例示例数 (0 表示不注入)
+	Version   string    `gorm:"type:varchar(50);uniqueIndex:idx_prompt_app_scene_version" json:"version"` // 当前版本号 (每次修改自动递增)
+	IsActive  bool      `gorm:"default:false" json:"is_active"`                                           // 是否为该场景的生效模板
 	Comment   string    `gorm:"type:varchar(255)" json:"comment"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SensitiveEntity 定义敏感实体词典条目 (政治人物、组织、事件等)
+type SensitiveEntity struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	AppID       string    `gorm:"type:varchar(64);index" json:"app_id"`   // 所属应用，为空表示全局词条
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"` // 标准名称
+	Aliases     string    `gorm:"type:varchar(1024)" json:"aliases"`      // 别名、简称、谐音 (逗号分隔)
+	Pinyin      string    `gorm:"type:varchar(255)" json:"pinyin"`        // 拼音全拼或缩写 (逗号分隔，如 "xijinping,xjp")
+	Category    string    `gorm:"type:varchar(50);index" json:"category"` // 类别 (如 "leader", "organization", "event")
+	Severity    int       `gorm:"default:1" json:"severity"`              // 严重度 (1-5，数字越大越敏感)
+	IsEnabled   bool      `json:"is_enabled"`                             // 是否启用 (创建时未指定则启用)
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -0,0 +1,240 @@
+// Package vectordb 维护 Milvus 中的案例向量集合
+package vectordb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	ark_embed "github.com/cloudwego/eino-ext/components/embedding/ark"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+const (
+	// queryPageSize 分页读取集合时每页的条数
+	queryPageSize = 1000
+	// MaxContentBytes 案例内容字段的最大长度，Milvus VarChar 按字节计 (约 680 个汉字)
+	MaxContentBytes = 2048
+)
+
+// ErrContentTooLong 案例内容超过 MaxContentBytes，无法写入集合，重试也不会成功
+var ErrContentTooLong = fmt.Errorf("案例内容超过 %d 字节", MaxContentBytes)
+
+// CaseStore 负责把 MySQL 案例库中的案例写入 Milvus 案例集合
+// 集合中的 case_id 字段记录对应的 MySQL 案例 ID，内置种子案例的 case_id 为 0
+type CaseStore struct {
+	client     *milvusclient.Client
+	embedder   embedding.Embedder
+	spec       Spec
+	collection string
+}
+
+// NewCaseStore 连接 Milvus 并初始化 Embedding
+func NewCaseStore(ctx context.Context, cfg *common.Config) (*CaseStore, error) {
+	if cfg.ArkEmbeddingModel == "" {
+		return nil, fmt.Errorf("未配置 ARK_EMBEDDING_MODEL")
+	}
+	emb, err := ark_embed.NewEmbedder(ctx, &ark_embed.EmbeddingConfig{
+		APIKey: cfg.ArkAPIKey,
+		Model:  cfg.ArkEmbeddingModel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化 embedding 失败: %w", err)
+	}
+	cli, err := milvusclient.New(ctx, &milvusclient.ClientConfig{Address: cfg.MilvusAddr})
+	if err != nil {
+		return nil, fmt.Errorf("连接 Milvus 失败: %w", err)
+	}
+	spec, err := NewSpec(ctx, cfg, emb)
+	if err != nil {
+		cli.Close(ctx)
+		return nil, err
+	}
+	return &CaseStore{client: cli, embedder: emb, spec: spec, collection: spec.Collection}, nil
+}
+
+// EnsureCollection 创建案例集合或校验已有集合的定义，见 EnsureCollection
+func (s *CaseStore) EnsureCollection(ctx context.Context, recreate bool) error {
+	return EnsureCollection(ctx, s.client, s.spec, recreate)
+}
+
+// Check 校验案例集合的定义，见 CheckCollection
+func (s *CaseStore) Check(ctx context.Context) error {
+	return CheckCollection(ctx, s.client, s.spec)
+}
+
+// Close 关闭 Milvus 连接
+func (s *CaseStore) Close(ctx context.Context) error {
+	return s.client.Close(ctx)
+}
+
+// Record 是写入案例集合的一条案例
+type Record struct {
+	CaseID   int64  // MySQL 案例 ID，来自文件的案例为 0
+	AppID    string // 所属应用，为空表示全局案例
+	Content  string
+	Label    string
+	Category string
+}
+
+// ContentHash 返回案例内容的哈希，用于增量导入时判断案例是否已存在
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Embed 批量计算案例向量
+func (s *CaseStore) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := s.embedder.EmbedStrings(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding 失败: %w", err)
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding 结果数量不符: 输入 %d 条, 返回 %d 条", len(texts), len(embeddings))
+	}
+	vectors := make([][]float32, len(embeddings))
+	for i, v64 := range embeddings {
+		v32 := make([]float32, len(v64))
+		for j, f := range v64 {
+			v32[j] = float32(f)
+		}
+		vectors[i] = v32
+	}
+	return vectors, nil
+}
+
+// Insert 写入一批案例及其向量，返回按顺序对应的向量 ID
+func (s *CaseStore) Insert(ctx context.Context, records []Record, vectors [][]float32) ([]int64, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	contents := make([]string, len(records))
+	labels := make([]string, len(records))
+	categories := make([]string, len(records))
+	hashes := make([]string, len(records))
+	caseIDs := make([]int64, len(records))
+	appIDs := make([]string, len(records))
+	for i, r := range records {
+		if len(r.Content) > MaxContentBytes {
+			return nil, fmt.Errorf("%w (第 %d 条, case=%d)", ErrContentTooLong, i, r.CaseID)
+		}
+		contents[i], labels[i], categories[i] = r.Content, r.Label, r.Category
+		hashes[i], caseIDs[i], appIDs[i] = ContentHash(r.Content), r.CaseID, r.AppID
+	}
+
+	result, err := s.client.Insert(ctx, milvusclient.NewColumnBasedInsertOption(s.collection).
+		WithFloatVectorColumn(FieldVector, len(vectors[0]), vectors).
+		WithVarcharColumn(FieldContent, contents).
+		WithVarcharColumn(FieldLabel, labels).
+		WithVarcharColumn(FieldCategory, categories).
+		WithVarcharColumn(FieldHash, hashes).
+		WithInt64Column(FieldCaseID, caseIDs).
+		WithVarcharColumn(FieldAppID, appIDs))
+	if err != nil {
+		return nil, fmt.Errorf("写入 Milvus 失败: %w", err)
+	}
+	if result.IDs == nil || result.IDs.Len() != len(records) {
+		return nil, fmt.Errorf("写入 Milvus 返回的 ID 数量不符")
+	}
+	ids := make([]int64, len(records))
+	for i := range ids {
+		if ids[i], err = result.IDs.GetAsInt64(i); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// Upsert 计算案例向量并写入集合，返回新的向量 ID
+// 集合主键为自增 ID，更新时先写入新向量再删除旧向量 (c.VectorID)，写入失败时旧向量保持不变
+// 内容超长时返回 ErrContentTooLong
+func (s *CaseStore) Upsert(ctx context.Context, c *common.Case) (int64, error) {
+	if len(c.Content) > MaxContentBytes {
+		return 0, ErrContentTooLong
+	}
+	vectors, err := s.Embed(ctx, []string{c.Content})
+	if err != nil {
+		return 0, err
+	}
+	ids, err := s.Insert(ctx, []Record{{CaseID: int64(c.ID), AppID: c.AppID, Content: c.Content, Label: c.Label, Category: c.Category}}, vectors)
+	if err != nil {
+		return 0, err
+	}
+	id := ids[0]
+
+	if c.VectorID != 0 && c.VectorID != id {
+		if err := s.Delete(ctx, c.VectorID); err != nil {
+			// 残留的旧向量由对账任务清理
+			log.Printf("删除旧案例向量失败 (case=%d, vector=%d): %v", c.ID, c.VectorID, err)
+		}
+	}
+	return id, nil
+}
+
+// Delete 删除指定 ID 的向量
+func (s *CaseStore) Delete(ctx context.Context, vectorIDs ...int64) error {
+	if len(vectorIDs) == 0 {
+		return nil
+	}
+	_, err := s.client.Delete(ctx, milvusclient.NewDeleteOption(s.collection).WithInt64IDs(FieldID, vectorIDs))
+	return err
+}
+
+// Entry 是集合中的一条案例向量 (不含向量本身)
+type Entry struct {
+	VectorID int64
+	CaseID   int64
+	AppID    string
+	Hash     string
+	Label    string
+	Category string
+}
+
+// Entries 返回集合中满足过滤条件的案例，如 "case_id > 0" 为所有由案例库同步的向量
+// 按主键分页读取 (id > 上一页的最大 ID)，不受 Milvus offset+limit 不超过 16384 的限制
+func (s *CaseStore) Entries(ctx context.Context, filter string) ([]Entry, error) {
+	var entries []Entry
+	var after int64 = -1
+	for {
+		rs, err := s.client.Query(ctx, milvusclient.NewQueryOption(s.collection).
+			WithFilter(pageFilter(filter, after)).
+			WithOutputFields(FieldID, FieldCaseID, FieldAppID, FieldHash, FieldLabel, FieldCategory).
+			WithLimit(queryPageSize))
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < rs.ResultCount; i++ {
+			var e Entry
+			if e.VectorID, err = rs.GetColumn(FieldID).GetAsInt64(i); err != nil {
+				return nil, err
+			}
+			if e.CaseID, err = rs.GetColumn(FieldCaseID).GetAsInt64(i); err != nil {
+				return nil, err
+			}
+			e.AppID, _ = rs.GetColumn(FieldAppID).GetAsString(i)
+			e.Hash, _ = rs.GetColumn(FieldHash).GetAsString(i)
+			e.Label, _ = rs.GetColumn(FieldLabel).GetAsString(i)
+			e.Category, _ = rs.GetColumn(FieldCategory).GetAsString(i)
+			entries = append(entries, e)
+			after = max(after, e.VectorID)
+		}
+		if rs.ResultCount < queryPageSize {
+			return entries, nil
+		}
+	}
+}
+
+// pageFilter 在过滤条件上追加主键游标条件
+func pageFilter(filter string, after int64) string {
+	cursor := FieldID + " > " + strconv.FormatInt(after, 10)
+	if strings.TrimSpace(filter) == "" {
+		return cursor
+	}
+	return "(" + filter + ") and " + cursor
+}
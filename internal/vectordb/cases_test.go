@@ -0,0 +1,21 @@
+package vectordb
+
+import "testing"
+
+func TestPageFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		after  int64
+		want   string
+	}{
+		{"", -1, "id > -1"},
+		{"  ", 42, "id > 42"},
+		{"case_id > 0", 100, "(case_id > 0) and id > 100"},
+		{`category == "a" or category == "b"`, 7, `(category == "a" or category == "b") and id > 7`},
+	}
+	for _, tt := range tests {
+		if got := pageFilter(tt.filter, tt.after); got != tt.want {
+			t.Errorf("pageFilter(%q, %d) = %q, want %q", tt.filter, tt.after, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package vectordb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/milvus-io/milvus/client/v2/entity"
+	"github.com/milvus-io/milvus/client/v2/index"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+	"github.com/safeflow-project/safeflow/internal/common"
+)
+
+// 案例集合的字段名
+const (
+	FieldID       = "id"
+	FieldVector   = "vector"
+	FieldContent  = "content"
+	FieldLabel    = "label"
+	FieldCategory = "category"
+	FieldHash     = "content_hash"
+	FieldCaseID   = "case_id"
+	FieldAppID    = "app_id"
+)
+
+// ErrCollectionNotFound 案例集合尚未创建
+var ErrCollectionNotFound = errors.New("案例集合不存在")
+
+// Spec 是案例集合的定义，init-milvus、api-gateway 与 llm-agent 共用，保证写入与检索使用同一套 Schema 和度量
+type Spec struct {
+	Collection string
+	Dim        int               // 向量维度，与 Embedding 模型一致
+	Metric     entity.MetricType // 相似度度量: COSINE, IP, L2
+	IndexType  string            // 向量索引类型: AUTOINDEX, IVF_FLAT, HNSW, FLAT
+}
+
+// NewSpec 根据配置和 Embedding 模型确定集合定义
+// 未配置 EMBEDDING_DIM 时调用一次 Embedding 获取向量维度
+func NewSpec(ctx context.Context, cfg *common.Config, emb embedding.Embedder) (Spec, error) {
+	spec := Spec{
+		Collection: cfg.RetrieverCollection,
+		Dim:        cfg.EmbeddingDim,
+		Metric:     entity.MetricType(strings.ToUpper(cfg.MilvusMetric)),
+		IndexType:  strings.ToUpper(cfg.MilvusIndexType),
+	}
+	switch spec.Metric {
+	case entity.COSINE, entity.IP, entity.L2:
+	default:
+		return spec, fmt.Errorf("不支持的相似度度量: %s", cfg.MilvusMetric)
+	}
+	if _, err := spec.index(); err != nil {
+		return spec, err
+	}
+	if spec.Dim <= 0 {
+		vectors, err := emb.EmbedStrings(ctx, []string{"dimension probe"})
+		if err != nil {
+			return spec, fmt.Errorf("获取 Embedding 维度失败: %w", err)
+		}
+		if len(vectors) == 0 || len(vectors[0]) == 0 {
+			return spec, fmt.Errorf("获取 Embedding 维度失败: 结果为空")
+		}
+		spec.Dim = len(vectors[0])
+	}
+	return spec, nil
+}
+
+// Schema 返回集合 Schema
+func (s Spec) Schema() *entity.Schema {
+	return entity.NewSchema().WithName(s.Collection).WithDescription("SafeFlow 敏感案例库").
+		WithField(entity.NewField().WithName(FieldID).WithDataType(entity.FieldTypeInt64).WithIsPrimaryKey(true).WithIsAutoID(true)).
+		WithField(entity.NewField().WithName(FieldVector).WithDataType(entity.FieldTypeFloatVector).WithDim(int64(s.Dim))).
+		WithField(entity.NewField().WithName(FieldContent).WithDataType(entity.FieldTypeVarChar).WithMaxLength(MaxContentBytes)).
+		WithField(entity.NewField().WithName(FieldLabel).WithDataType(entity.FieldTypeVarChar).WithMaxLength(64)).
+		WithField(entity.NewField().WithName(FieldCategory).WithDataType(entity.FieldTypeVarChar).WithMaxLength(64)).
+		WithField(entity.NewField().WithName(FieldHash).WithDataType(entity.FieldTypeVarChar).WithMaxLength(64)).
+		WithField(entity.NewField().WithName(FieldCaseID).WithDataType(entity.FieldTypeInt64)).
+		WithField(entity.NewField().WithName(FieldAppID).WithDataType(entity.FieldTypeVarChar).WithMaxLength(64))
+}
+
+// index 返回向量字段的索引定义
+func (s Spec) index() (index.Index, error) {
+	switch s.IndexType {
+	case "", string(index.AUTOINDEX):
+		return index.NewAutoIndex(s.Metric), nil
+	case string(index.IvfFlat):
+		return index.NewIvfFlatIndex(s.Metric, 128), nil
+	case string(index.HNSW):
+		return index.NewHNSWIndex(s.Metric, 16, 200), nil
+	case string(index.Flat):
+		return index.NewFlatIndex(s.Metric), nil
+	}
+	return nil, fmt.Errorf("不支持的索引类型: %s", s.IndexType)
+}
+
+// EnsureCollection 创建案例集合 (已存在时校验定义并保留现有数据) 并加载到内存
+// recreate 为 true 时先删除已有集合，用于 Schema、维度或度量变更后的全量重建
+func EnsureCollection(ctx context.Context, cli *milvusclient.Client, spec Spec, recreate bool) error {
+	has, err := cli.HasCollection(ctx, milvusclient.NewHasCollectionOption(spec.Collection))
+	if err != nil {
+		return fmt.Errorf("检查集合失败: %w", err)
+	}
+	if has && recreate {
+		if err := cli.DropCollection(ctx, milvusclient.NewDropCollectionOption(spec.Collection)); err != nil {
+			return fmt.Errorf("删除集合失败: %w", err)
+		}
+		has = false
+	}
+	if has {
+		if err := CheckCollection(ctx, cli, spec); err != nil {
+			return err
+		}
+	} else {
+		idx, err := spec.index()
+		if err != nil {
+			return err
+		}
+		err = cli.CreateCollection(ctx, milvusclient.NewCreateCollectionOption(spec.Collection, spec.Schema()).
+			WithIndexOptions(milvusclient.NewCreateIndexOption(spec.Collection, FieldVector, idx)))
+		if err != nil {
+			return fmt.Errorf("创建集合失败: %w", err)
+		}
+	}
+
+	task, err := cli.LoadCollection(ctx, milvusclient.NewLoadCollectionOption(spec.Collection))
+	if err != nil {
+		return fmt.Errorf("加载集合失败: %w", err)
+	}
+	return task.Await(ctx)
+}
+
+// CheckCollection 校验已有集合的字段、向量维度与索引度量是否与定义一致
+// 集合不存在时返回 ErrCollectionNotFound
+func CheckCollection(ctx context.Context, cli *milvusclient.Client, spec Spec) error {
+	has, err := cli.HasCollection(ctx, milvusclient.NewHasCollectionOption(spec.Collection))
+	if err != nil {
+		return fmt.Errorf("检查集合失败: %w", err)
+	}
+	if !has {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, spec.Collection)
+	}
+	coll, err := cli.DescribeCollection(ctx, milvusclient.NewDescribeCollectionOption(spec.Collection))
+	if err != nil {
+		return fmt.Errorf("读取集合定义失败: %w", err)
+	}
+
+	actual := make(map[string]*entity.Field)
+	for _, f := range coll.Schema.Fields {
+		actual[f.Name] = f
+	}
+	var problems []string
+	for _, want := range spec.Schema().Fields {
+		got, ok := actual[want.Name]
+		if !ok {
+			problems = append(problems, "缺少字段 "+want.Name)
+			continue
+		}
+		if got.DataType != want.DataType {
+			problems = append(problems, fmt.Sprintf("字段 %s 类型为 %s，应为 %s", want.Name, got.DataType.Name(), want.DataType.Name()))
+		}
+	}
+	if f, ok := actual[FieldVector]; ok {
+		if dim, _ := strconv.Atoi(f.TypeParams[entity.TypeParamDim]); dim != spec.Dim {
+			problems = append(problems, fmt.Sprintf("向量维度为 %d，Embedding 模型维度为 %d", dim, spec.Dim))
+		}
+	}
+
+	names, err := cli.ListIndexes(ctx, milvusclient.NewListIndexOption(spec.Collection).WithFieldName(FieldVector))
+	if err != nil {
+		return fmt.Errorf("读取索引失败: %w", err)
+	}
+	if len(names) == 0 {
+		problems = append(problems, "向量字段没有索引")
+	} else {
+		desc, err := cli.DescribeIndex(ctx, milvusclient.NewDescribeIndexOption(spec.Collection, names[0]))
+		if err != nil {
+			return fmt.Errorf("读取索引失败: %w", err)
+		}
+		if metric := desc.Params()[index.MetricTypeKey]; !strings.EqualFold(metric, string(spec.Metric)) {
+			problems = append(problems, fmt.Sprintf("索引度量为 %s，配置为 %s", metric, spec.Metric))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("集合 %s 与定义不一致 (可使用 init-milvus -recreate 重建): %s", spec.Collection, strings.Join(problems, "; "))
+	}
+	return nil
+}
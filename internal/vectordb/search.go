@@ -0,0 +1,119 @@
+package vectordb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/milvus-io/milvus/client/v2/entity"
+	"github.com/milvus-io/milvus/client/v2/milvusclient"
+)
+
+// Hit 是一条相似案例检索结果
+type Hit struct {
+	VectorID int64   `json:"vector_id"`
+	CaseID   int64   `json:"case_id"` // MySQL 案例 ID，内置种子案例为 0
+	AppID    string  `json:"app_id"`  // 所属应用，为空表示全局案例
+	Content  string  `json:"content"`
+	Label    string  `json:"label"`
+	Category string  `json:"category"`
+	Score    float32 `json:"score"` // 相似度 (COSINE/IP 越大越相似，L2 为距离，越小越相似)
+}
+
+// Metric 返回案例集合的相似度度量
+func (s *CaseStore) Metric() entity.MetricType {
+	return s.spec.Metric
+}
+
+// Search 计算文本向量并检索最相似的 topK 条案例
+func (s *CaseStore) Search(ctx context.Context, text string, topK int, filter string) ([]Hit, error) {
+	vectors, err := s.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	hits, err := s.SearchVectors(ctx, vectors, topK, filter)
+	if err != nil {
+		return nil, err
+	}
+	return hits[0], nil
+}
+
+// SearchVectors 以一批向量检索，返回按输入顺序对应的相似案例
+func (s *CaseStore) SearchVectors(ctx context.Context, vectors [][]float32, topK int, filter string) ([][]Hit, error) {
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	queries := make([]entity.Vector, len(vectors))
+	for i, v := range vectors {
+		queries[i] = entity.FloatVector(v)
+	}
+	opt := milvusclient.NewSearchOption(s.collection, topK, queries).
+		WithANNSField(FieldVector).
+		WithOutputFields(FieldCaseID, FieldAppID, FieldContent, FieldLabel, FieldCategory)
+	if filter != "" {
+		opt = opt.WithFilter(filter)
+	}
+	results, err := s.client.Search(ctx, opt)
+	if err != nil {
+		return nil, fmt.Errorf("检索 Milvus 失败: %w", err)
+	}
+	if len(results) != len(vectors) {
+		return nil, fmt.Errorf("检索 Milvus 返回的结果数量不符")
+	}
+
+	out := make([][]Hit, len(results))
+	for i, rs := range results {
+		if rs.Err != nil {
+			return nil, rs.Err
+		}
+		hits := make([]Hit, 0, rs.ResultCount)
+		for j := 0; j < rs.ResultCount; j++ {
+			h := Hit{Score: rs.Scores[j]}
+			if h.VectorID, err = rs.IDs.GetAsInt64(j); err != nil {
+				return nil, err
+			}
+			h.CaseID, _ = rs.GetColumn(FieldCaseID).GetAsInt64(j)
+			h.AppID, _ = rs.GetColumn(FieldAppID).GetAsString(j)
+			h.Content, _ = rs.GetColumn(FieldContent).GetAsString(j)
+			h.Label, _ = rs.GetColumn(FieldLabel).GetAsString(j)
+			h.Category, _ = rs.GetColumn(FieldCategory).GetAsString(j)
+			hits = append(hits, h)
+		}
+		out[i] = hits
+	}
+	return out, nil
+}
+
+// Vectors 读取指定向量 ID 已存储的向量，不存在的 ID 不出现在结果中
+// 用于以已有案例检索相似案例，避免重复计算 Embedding
+func (s *CaseStore) Vectors(ctx context.Context, vectorIDs []int64) (map[int64][]float32, error) {
+	out := make(map[int64][]float32, len(vectorIDs))
+	for start := 0; start < len(vectorIDs); start += queryPageSize {
+		end := min(start+queryPageSize, len(vectorIDs))
+		ids := make([]string, 0, end-start)
+		for _, id := range vectorIDs[start:end] {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		rs, err := s.client.Query(ctx, milvusclient.NewQueryOption(s.collection).
+			WithFilter(FieldID+" in ["+strings.Join(ids, ", ")+"]").
+			WithOutputFields(FieldID, FieldVector))
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < rs.ResultCount; i++ {
+			id, err := rs.GetColumn(FieldID).GetAsInt64(i)
+			if err != nil {
+				return nil, err
+			}
+			value, err := rs.GetColumn(FieldVector).Get(i)
+			if err != nil {
+				return nil, err
+			}
+			if v, ok := value.(entity.FloatVector); ok {
+				out[id] = v
+			}
+		}
+	}
+	return out, nil
+}
@@ -12,6 +12,7 @@ import (
 // Client is designed to provide IDL-compatible methods with call-option parameter for kitex framework.
 type Client interface {
 	Scan(ctx context.Context, req *safeflow.ScanRequest, callOptions ...callopt.Option) (r *safeflow.ScanResponse, err error)
+	Stats(ctx context.Context, req *safeflow.StatsRequest, callOptions ...callopt.Option) (r *safeflow.StatsResponse, err error)
 }
 
 // NewClient creates a client for the service defined in IDL.
@@ -47,3 +48,8 @@ func (p *kLLMAgentServiceClient) Scan(ctx context.Context, req *safeflow.ScanReq
 	ctx = client.NewCtxWithCallOptions(ctx, callOptions)
 	return p.kClient.Scan(ctx, req)
 }
+
+func (p *kLLMAgentServiceClient) Stats(ctx context.Context, req *safeflow.StatsRequest, callOptions ...callopt.Option) (r *safeflow.StatsResponse, err error) {
+	ctx = client.NewCtxWithCallOptions(ctx, callOptions)
+	return p.kClient.Stats(ctx, req)
+}
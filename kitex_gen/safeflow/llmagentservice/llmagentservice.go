@@ -20,6 +20,13 @@ var serviceMethods = map[string]kitex.MethodInfo{
 		false,
 		kitex.WithStreamingMode(kitex.StreamingNone),
 	),
+	"Stats": kitex.NewMethodInfo(
+		statsHandler,
+		newLLMAgentServiceStatsArgs,
+		newLLMAgentServiceStatsResult,
+		false,
+		kitex.WithStreamingMode(kitex.StreamingNone),
+	),
 }
 
 var (
@@ -104,6 +111,24 @@ func newLLMAgentServiceScanResult() interface{} {
 	return safeflow.NewLLMAgentServiceScanResult()
 }
 
+func statsHandler(ctx context.Context, handler interface{}, arg, result interface{}) error {
+	realArg := arg.(*safeflow.LLMAgentServiceStatsArgs)
+	realResult := result.(*safeflow.LLMAgentServiceStatsResult)
+	success, err := handler.(safeflow.LLMAgentService).Stats(ctx, realArg.Req)
+	if err != nil {
+		return err
+	}
+	realResult.Success = success
+	return nil
+}
+func newLLMAgentServiceStatsArgs() interface{} {
+	return safeflow.NewLLMAgentServiceStatsArgs()
+}
+
+func newLLMAgentServiceStatsResult() interface{} {
+	return safeflow.NewLLMAgentServiceStatsResult()
+}
+
 type kClient struct {
 	c client.Client
 }
@@ -123,3 +148,13 @@ func (p *kClient) Scan(ctx context.Context, req *safeflow.ScanRequest) (r *safef
 	}
 	return _result.GetSuccess(), nil
 }
+
+func (p *kClient) Stats(ctx context.Context, req *safeflow.StatsRequest) (r *safeflow.StatsResponse, err error) {
+	var _args safeflow.LLMAgentServiceStatsArgs
+	_args.Req = req
+	var _result safeflow.LLMAgentServiceStatsResult
+	if err = p.c.Call(ctx, "Stats", &_args, &_result); err != nil {
+		return
+	}
+	return _result.GetSuccess(), nil
+}
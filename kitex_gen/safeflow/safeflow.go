@@ -7,10 +7,64 @@ import (
 	"fmt"
 )
 
+type ChatMessage struct {
+	Role    string `thrift:"role,1" frugal:"1,default,string" json:"role"`
+	Content string `thrift:"content,2" frugal:"2,default,string" json:"content"`
+	UserId  string `thrift:"user_id,3" frugal:"3,default,string" json:"user_id"`
+}
+
+func NewChatMessage() *ChatMessage {
+	return &ChatMessage{}
+}
+
+func (p *ChatMessage) InitDefault() {
+}
+
+func (p *ChatMessage) GetRole() (v string) {
+	return p.Role
+}
+
+func (p *ChatMessage) GetContent() (v string) {
+	return p.Content
+}
+
+func (p *ChatMessage) GetUserId() (v string) {
+	return p.UserId
+}
+func (p *ChatMessage) SetRole(val string) {
+	p.Role = val
+}
+func (p *ChatMessage) SetContent(val string) {
+	p.Content = val
+}
+func (p *ChatMessage) SetUserId(val string) {
+	p.UserId = val
+}
+
+func (p *ChatMessage) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("ChatMessage(%+v)", *p)
+}
+
+var fieldIDToName_ChatMessage = map[int16]string{
+	1: "role",
+	2: "content",
+	3: "user_id",
+}
+
 type ScanRequest struct {
-	RequestId string `thrift:"request_id,1" frugal:"1,default,string" json:"request_id"`
-	UserId    string `thrift:"user_id,2" frugal:"2,default,string" json:"user_id"`
-	Content   string `thrift:"content,3" frugal:"3,default,string" json:"content"`
+	RequestId    string         `thrift:"request_id,1" frugal:"1,default,string" json:"request_id"`
+	UserId       string         `thrift:"user_id,2" frugal:"2,default,string" json:"user_id"`
+	Content      string         `thrift:"content,3" frugal:"3,default,string" json:"content"`
+	Scene        string         `thrift:"scene,4" frugal:"4,default,string" json:"scene"`
+	Language     string         `thrift:"language,5" frugal:"5,default,string" json:"language"`
+	Mode         string         `thrift:"mode,6" frugal:"6,default,string" json:"mode"`
+	Conversation []*ChatMessage `thrift:"conversation,7" frugal:"7,default,list<ChatMessage>" json:"conversation"`
+	TargetIndex  int32          `thrift:"target_index,8" frugal:"8,default,i32" json:"target_index"`
+	IncludeTrace bool           `thrift:"include_trace,9" frugal:"9,default,bool" json:"include_trace"`
+	AppId        string         `thrift:"app_id,10" frugal:"10,default,string" json:"app_id"`
 }
 
 func NewScanRequest() *ScanRequest {
@@ -31,6 +85,34 @@ func (p *ScanRequest) GetUserId() (v string) {
 func (p *ScanRequest) GetContent() (v string) {
 	return p.Content
 }
+
+func (p *ScanRequest) GetScene() (v string) {
+	return p.Scene
+}
+
+func (p *ScanRequest) GetLanguage() (v string) {
+	return p.Language
+}
+
+func (p *ScanRequest) GetMode() (v string) {
+	return p.Mode
+}
+
+func (p *ScanRequest) GetConversation() (v []*ChatMessage) {
+	return p.Conversation
+}
+
+func (p *ScanRequest) GetTargetIndex() (v int32) {
+	return p.TargetIndex
+}
+
+func (p *ScanRequest) GetIncludeTrace() (v bool) {
+	return p.IncludeTrace
+}
+
+func (p *ScanRequest) GetAppId() (v string) {
+	return p.AppId
+}
 func (p *ScanRequest) SetRequestId(val string) {
 	p.RequestId = val
 }
@@ -40,6 +122,27 @@ func (p *ScanRequest) SetUserId(val string) {
 func (p *ScanRequest) SetContent(val string) {
 	p.Content = val
 }
+func (p *ScanRequest) SetScene(val string) {
+	p.Scene = val
+}
+func (p *ScanRequest) SetLanguage(val string) {
+	p.Language = val
+}
+func (p *ScanRequest) SetMode(val string) {
+	p.Mode = val
+}
+func (p *ScanRequest) SetConversation(val []*ChatMessage) {
+	p.Conversation = val
+}
+func (p *ScanRequest) SetTargetIndex(val int32) {
+	p.TargetIndex = val
+}
+func (p *ScanRequest) SetIncludeTrace(val bool) {
+	p.IncludeTrace = val
+}
+func (p *ScanRequest) SetAppId(val string) {
+	p.AppId = val
+}
 
 func (p *ScanRequest) String() string {
 	if p == nil {
@@ -49,16 +152,244 @@ func (p *ScanRequest) String() string {
 }
 
 var fieldIDToName_ScanRequest = map[int16]string{
-	1: "request_id",
-	2: "user_id",
-	3: "content",
+	1:  "request_id",
+	2:  "user_id",
+	3:  "content",
+	4:  "scene",
+	5:  "language",
+	6:  "mode",
+	7:  "conversation",
+	8:  "target_index",
+	9:  "include_trace",
+	10: "app_id",
+}
+
+type Span struct {
+	Start int32  `thrift:"start,1" frugal:"1,default,i32" json:"start"`
+	End   int32  `thrift:"end,2" frugal:"2,default,i32" json:"end"`
+	Text  string `thrift:"text,3" frugal:"3,default,string" json:"text"`
+	Label string `thrift:"label,4" frugal:"4,default,string" json:"label"`
+}
+
+func NewSpan() *Span {
+	return &Span{}
+}
+
+func (p *Span) InitDefault() {
+}
+
+func (p *Span) GetStart() (v int32) {
+	return p.Start
+}
+
+func (p *Span) GetEnd() (v int32) {
+	return p.End
+}
+
+func (p *Span) GetText() (v string) {
+	return p.Text
+}
+
+func (p *Span) GetLabel() (v string) {
+	return p.Label
+}
+func (p *Span) SetStart(val int32) {
+	p.Start = val
+}
+func (p *Span) SetEnd(val int32) {
+	p.End = val
+}
+func (p *Span) SetText(val string) {
+	p.Text = val
+}
+func (p *Span) SetLabel(val string) {
+	p.Label = val
+}
+
+func (p *Span) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("Span(%+v)", *p)
+}
+
+var fieldIDToName_Span = map[int16]string{
+	1: "start",
+	2: "end",
+	3: "text",
+	4: "label",
+}
+
+type MemberVerdict struct {
+	Name   string `thrift:"name,1" frugal:"1,default,string" json:"name"`
+	Action string `thrift:"action,2" frugal:"2,default,string" json:"action"`
+	Reason string `thrift:"reason,3" frugal:"3,default,string" json:"reason"`
+	Error  string `thrift:"error,4" frugal:"4,default,string" json:"error"`
+}
+
+func NewMemberVerdict() *MemberVerdict {
+	return &MemberVerdict{}
+}
+
+func (p *MemberVerdict) InitDefault() {
+}
+
+func (p *MemberVerdict) GetName() (v string) {
+	return p.Name
+}
+
+func (p *MemberVerdict) GetAction() (v string) {
+	return p.Action
+}
+
+func (p *MemberVerdict) GetReason() (v string) {
+	return p.Reason
+}
+
+func (p *MemberVerdict) GetError() (v string) {
+	return p.Error
+}
+func (p *MemberVerdict) SetName(val string) {
+	p.Name = val
+}
+func (p *MemberVerdict) SetAction(val string) {
+	p.Action = val
+}
+func (p *MemberVerdict) SetReason(val string) {
+	p.Reason = val
+}
+func (p *MemberVerdict) SetError(val string) {
+	p.Error = val
+}
+
+func (p *MemberVerdict) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("MemberVerdict(%+v)", *p)
+}
+
+var fieldIDToName_MemberVerdict = map[int16]string{
+	1: "name",
+	2: "action",
+	3: "reason",
+	4: "error",
+}
+
+type TraceStep struct {
+	Type             string `thrift:"type,1" frugal:"1,default,string" json:"type"`
+	Name             string `thrift:"name,2" frugal:"2,default,string" json:"name"`
+	Input            string `thrift:"input,3" frugal:"3,default,string" json:"input"`
+	Output           string `thrift:"output,4" frugal:"4,default,string" json:"output"`
+	Error            string `thrift:"error,5" frugal:"5,default,string" json:"error"`
+	PromptTokens     int32  `thrift:"prompt_tokens,6" frugal:"6,default,i32" json:"prompt_tokens"`
+	CompletionTokens int32  `thrift:"completion_tokens,7" frugal:"7,default,i32" json:"completion_tokens"`
+	TotalTokens      int32  `thrift:"total_tokens,8" frugal:"8,default,i32" json:"total_tokens"`
+	LatencyMs        int64  `thrift:"latency_ms,9" frugal:"9,default,i64" json:"latency_ms"`
+}
+
+func NewTraceStep() *TraceStep {
+	return &TraceStep{}
+}
+
+func (p *TraceStep) InitDefault() {
+}
+
+func (p *TraceStep) GetType() (v string) {
+	return p.Type
+}
+
+func (p *TraceStep) GetName() (v string) {
+	return p.Name
+}
+
+func (p *TraceStep) GetInput() (v string) {
+	return p.Input
+}
+
+func (p *TraceStep) GetOutput() (v string) {
+	return p.Output
+}
+
+func (p *TraceStep) GetError() (v string) {
+	return p.Error
+}
+
+func (p *TraceStep) GetPromptTokens() (v int32) {
+	return p.PromptTokens
+}
+
+func (p *TraceStep) GetCompletionTokens() (v int32) {
+	return p.CompletionTokens
+}
+
+func (p *TraceStep) GetTotalTokens() (v int32) {
+	return p.TotalTokens
+}
+
+func (p *TraceStep) GetLatencyMs() (v int64) {
+	return p.LatencyMs
+}
+func (p *TraceStep) SetType(val string) {
+	p.Type = val
+}
+func (p *TraceStep) SetName(val string) {
+	p.Name = val
+}
+func (p *TraceStep) SetInput(val string) {
+	p.Input = val
+}
+func (p *TraceStep) SetOutput(val string) {
+	p.Output = val
+}
+func (p *TraceStep) SetError(val string) {
+	p.Error = val
+}
+func (p *TraceStep) SetPromptTokens(val int32) {
+	p.PromptTokens = val
+}
+func (p *TraceStep) SetCompletionTokens(val int32) {
+	p.CompletionTokens = val
+}
+func (p *TraceStep) SetTotalTokens(val int32) {
+	p.TotalTokens = val
+}
+func (p *TraceStep) SetLatencyMs(val int64) {
+	p.LatencyMs = val
+}
+
+func (p *TraceStep) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("TraceStep(%+v)", *p)
+}
+
+var fieldIDToName_TraceStep = map[int16]string{
+	1: "type",
+	2: "name",
+	3: "input",
+	4: "output",
+	5: "error",
+	6: "prompt_tokens",
+	7: "completion_tokens",
+	8: "total_tokens",
+	9: "latency_ms",
 }
 
 type ScanResponse struct {
-	RequestId string `thrift:"request_id,1" frugal:"1,default,string" json:"request_id"`
-	Action    string `thrift:"action,2" frugal:"2,default,string" json:"action"`
-	Reason    string `thrift:"reason,3" frugal:"3,default,string" json:"reason"`
-	Source    string `thrift:"source,4" frugal:"4,default,string" json:"source"`
+	RequestId     string           `thrift:"request_id,1" frugal:"1,default,string" json:"request_id"`
+	Action        string           `thrift:"action,2" frugal:"2,default,string" json:"action"`
+	Reason        string           `thrift:"reason,3" frugal:"3,default,string" json:"reason"`
+	Source        string           `thrift:"source,4" frugal:"4,default,string" json:"source"`
+	PromptVersion string           `thrift:"prompt_version,5" frugal:"5,default,string" json:"prompt_version"`
+	Rewrite       string           `thrift:"rewrite,6" frugal:"6,default,string" json:"rewrite"`
+	Spans         []*Span          `thrift:"spans,7" frugal:"7,default,list<Span>" json:"spans"`
+	Members       []*MemberVerdict `thrift:"members,8" frugal:"8,default,list<MemberVerdict>" json:"members"`
+	Category      string           `thrift:"category,9" frugal:"9,default,string" json:"category"`
+	Confidence    float64          `thrift:"confidence,10" frugal:"10,default,double" json:"confidence"`
+	Tier          string           `thrift:"tier,11" frugal:"11,default,string" json:"tier"`
+	Trace         []*TraceStep     `thrift:"trace,12" frugal:"12,default,list<TraceStep>" json:"trace"`
 }
 
 func NewScanResponse() *ScanResponse {
@@ -83,6 +414,38 @@ func (p *ScanResponse) GetReason() (v string) {
 func (p *ScanResponse) GetSource() (v string) {
 	return p.Source
 }
+
+func (p *ScanResponse) GetPromptVersion() (v string) {
+	return p.PromptVersion
+}
+
+func (p *ScanResponse) GetRewrite() (v string) {
+	return p.Rewrite
+}
+
+func (p *ScanResponse) GetSpans() (v []*Span) {
+	return p.Spans
+}
+
+func (p *ScanResponse) GetMembers() (v []*MemberVerdict) {
+	return p.Members
+}
+
+func (p *ScanResponse) GetCategory() (v string) {
+	return p.Category
+}
+
+func (p *ScanResponse) GetConfidence() (v float64) {
+	return p.Confidence
+}
+
+func (p *ScanResponse) GetTier() (v string) {
+	return p.Tier
+}
+
+func (p *ScanResponse) GetTrace() (v []*TraceStep) {
+	return p.Trace
+}
 func (p *ScanResponse) SetRequestId(val string) {
 	p.RequestId = val
 }
@@ -95,6 +458,30 @@ func (p *ScanResponse) SetReason(val string) {
 func (p *ScanResponse) SetSource(val string) {
 	p.Source = val
 }
+func (p *ScanResponse) SetPromptVersion(val string) {
+	p.PromptVersion = val
+}
+func (p *ScanResponse) SetRewrite(val string) {
+	p.Rewrite = val
+}
+func (p *ScanResponse) SetSpans(val []*Span) {
+	p.Spans = val
+}
+func (p *ScanResponse) SetMembers(val []*MemberVerdict) {
+	p.Members = val
+}
+func (p *ScanResponse) SetCategory(val string) {
+	p.Category = val
+}
+func (p *ScanResponse) SetConfidence(val float64) {
+	p.Confidence = val
+}
+func (p *ScanResponse) SetTier(val string) {
+	p.Tier = val
+}
+func (p *ScanResponse) SetTrace(val []*TraceStep) {
+	p.Trace = val
+}
 
 func (p *ScanResponse) String() string {
 	if p == nil {
@@ -104,10 +491,66 @@ func (p *ScanResponse) String() string {
 }
 
 var fieldIDToName_ScanResponse = map[int16]string{
-	1: "request_id",
-	2: "action",
-	3: "reason",
-	4: "source",
+	1:  "request_id",
+	2:  "action",
+	3:  "reason",
+	4:  "source",
+	5:  "prompt_version",
+	6:  "rewrite",
+	7:  "spans",
+	8:  "members",
+	9:  "category",
+	10: "confidence",
+	11: "tier",
+	12: "trace",
+}
+
+type StatsRequest struct {
+}
+
+func NewStatsRequest() *StatsRequest {
+	return &StatsRequest{}
+}
+
+func (p *StatsRequest) InitDefault() {
+}
+
+func (p *StatsRequest) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("StatsRequest(%+v)", *p)
+}
+
+var fieldIDToName_StatsRequest = map[int16]string{}
+
+type StatsResponse struct {
+	Metrics map[string]float64 `thrift:"metrics,1" frugal:"1,default,map<string:double>" json:"metrics"`
+}
+
+func NewStatsResponse() *StatsResponse {
+	return &StatsResponse{}
+}
+
+func (p *StatsResponse) InitDefault() {
+}
+
+func (p *StatsResponse) GetMetrics() (v map[string]float64) {
+	return p.Metrics
+}
+func (p *StatsResponse) SetMetrics(val map[string]float64) {
+	p.Metrics = val
+}
+
+func (p *StatsResponse) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("StatsResponse(%+v)", *p)
+}
+
+var fieldIDToName_StatsResponse = map[int16]string{
+	1: "metrics",
 }
 
 type RuleEngineService interface {
@@ -192,6 +635,8 @@ var fieldIDToName_RuleEngineServiceScanResult = map[int16]string{
 
 type LLMAgentService interface {
 	Scan(ctx context.Context, req *ScanRequest) (r *ScanResponse, err error)
+
+	Stats(ctx context.Context, req *StatsRequest) (r *StatsResponse, err error)
 }
 
 type LLMAgentServiceScanArgs struct {
@@ -269,3 +714,79 @@ func (p *LLMAgentServiceScanResult) String() string {
 var fieldIDToName_LLMAgentServiceScanResult = map[int16]string{
 	0: "success",
 }
+
+type LLMAgentServiceStatsArgs struct {
+	Req *StatsRequest `thrift:"req,1" frugal:"1,default,StatsRequest" json:"req"`
+}
+
+func NewLLMAgentServiceStatsArgs() *LLMAgentServiceStatsArgs {
+	return &LLMAgentServiceStatsArgs{}
+}
+
+func (p *LLMAgentServiceStatsArgs) InitDefault() {
+}
+
+var LLMAgentServiceStatsArgs_Req_DEFAULT *StatsRequest
+
+func (p *LLMAgentServiceStatsArgs) GetReq() (v *StatsRequest) {
+	if !p.IsSetReq() {
+		return LLMAgentServiceStatsArgs_Req_DEFAULT
+	}
+	return p.Req
+}
+func (p *LLMAgentServiceStatsArgs) SetReq(val *StatsRequest) {
+	p.Req = val
+}
+
+func (p *LLMAgentServiceStatsArgs) IsSetReq() bool {
+	return p.Req != nil
+}
+
+func (p *LLMAgentServiceStatsArgs) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("LLMAgentServiceStatsArgs(%+v)", *p)
+}
+
+var fieldIDToName_LLMAgentServiceStatsArgs = map[int16]string{
+	1: "req",
+}
+
+type LLMAgentServiceStatsResult struct {
+	Success *StatsResponse `thrift:"success,0,optional" frugal:"0,optional,StatsResponse" json:"success,omitempty"`
+}
+
+func NewLLMAgentServiceStatsResult() *LLMAgentServiceStatsResult {
+	return &LLMAgentServiceStatsResult{}
+}
+
+func (p *LLMAgentServiceStatsResult) InitDefault() {
+}
+
+var LLMAgentServiceStatsResult_Success_DEFAULT *StatsResponse
+
+func (p *LLMAgentServiceStatsResult) GetSuccess() (v *StatsResponse) {
+	if !p.IsSetSuccess() {
+		return LLMAgentServiceStatsResult_Success_DEFAULT
+	}
+	return p.Success
+}
+func (p *LLMAgentServiceStatsResult) SetSuccess(x interface{}) {
+	p.Success = x.(*StatsResponse)
+}
+
+func (p *LLMAgentServiceStatsResult) IsSetSuccess() bool {
+	return p.Success != nil
+}
+
+func (p *LLMAgentServiceStatsResult) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("LLMAgentServiceStatsResult(%+v)", *p)
+}
+
+var fieldIDToName_LLMAgentServiceStatsResult = map[int16]string{
+	0: "success",
+}
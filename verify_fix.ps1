@@ -5,6 +5,8 @@ $env:LLM_AGENT_PORT="8892"
 $env:RULE_ENGINE_ADDR="localhost:8891"
 $env:LLM_AGENT_ADDR="localhost:8892"
 $env:CONFIG_FILE="config.yaml"
+# 测试环境不创建应用，允许不带 API Key 提交 (设置 SAFEFLOW_API_KEY 时 verify 会携带)
+$env:API_KEY_REQUIRED="false"
 
 Write-Host "🚀 正在启动测试环境 (Shadow Stack)..."
 